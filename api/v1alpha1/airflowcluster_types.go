@@ -38,8 +38,12 @@ const (
 	WebserversRoleName          RoleName = "webservers"
 	CeleryExecutorsRoleName     RoleName = "celeryexecutors"
 	KubernetesExecutorsRoleName RoleName = "kubernetesexecutors"
+	TriggerersRoleName          RoleName = "triggerers"
+	DagProcessorsRoleName       RoleName = "dagprocessors"
+	FlowerRoleName              RoleName = "flower"
 )
 
+// +kubebuilder:validation:XValidation:rule="!has(self.custom) || has(self.productVersion)",message="productVersion is required with a custom image"
 type ImageSpec struct {
 	// +kubebuilder:validation:Optional
 	Custom string `json:"custom,omitempty"`
@@ -52,8 +56,9 @@ type ImageSpec struct {
 	// +kubebuilder:default="0.0.0-dev"
 	KubedoopVersion string `json:"kubedoopVersion,omitempty"`
 
+	// The airflow version of the image, it selects the airflow 2 or 3 configuration.
+	// Defaults to 2.10.2, it is required with a custom image.
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default="2.10.2"
 	ProductVersion string `json:"productVersion,omitempty"`
 
 	// +kubebuilder:validation:Optional
@@ -68,6 +73,8 @@ type AuthenticationSpec struct {
 	// +kubebuilder:validation:Optional
 	AuthenticationClass string `json:"authenticationClass,omitempty"`
 
+	// Client credentials of an OIDC authentication class. The redirect URI of a single OIDC provider
+	// is /oauth-authorized/keycloak, with several providers it is /oauth-authorized/<authenticationClass>.
 	// +kubebuilder:validation:Optional
 	Oidc *authenticationv1alpha1.OidcSpec `json:"oidc,omitempty"`
 
@@ -81,21 +88,49 @@ type AuthenticationSpec struct {
 
 	// +kubebuilder:validation:Optional
 	UserRegistrationRole string `json:"userRegistrationRole,omitempty"`
+
+	// Maps directory groups to Airflow roles, e.g. `cn=admins,ou=groups,dc=example,dc=org: [Admin]`.
+	// The keys are LDAP group DNs or values of the OIDC `rolesClaim`, the values are FAB roles
+	// like Admin, Op, User, Viewer or custom roles.
+	// Combine it with `syncRolesAt: Login` to make the groups the source of truth.
+	// +kubebuilder:validation:Optional
+	RoleMapping map[string][]string `json:"roleMapping,omitempty"`
+
+	// Negotiates TLS with StartTLS on the plain LDAP port instead of connecting with LDAPS.
+	// Only used by ldap authentication classes with TLS, the port then defaults to 389 instead of 636.
+	// +kubebuilder:validation:Optional
+	LdapStartTLS bool `json:"ldapStartTLS,omitempty"`
+
+	// OIDC claim holding the groups or roles of the user, nested claims are separated by dots,
+	// e.g. `groups` or `realm_access.roles`. Its values are mapped to Airflow roles through `roleMapping`,
+	// roles are synced at login unless `syncRolesAt` is set. Only used by oidc authentication classes.
+	// +kubebuilder:validation:Optional
+	RolesClaim string `json:"rolesClaim,omitempty"`
 }
 
 type DagsGitSyncSpec struct {
 	// +kubebuilder:validation:Optional
 	Branch string `json:"branch,omitempty"`
 
+	// Secret with the HTTPS credentials of the repo, it should contain the keys `user` and `password`.
 	// +kubebuilder:validation:Optional
 	CrdentialsSecret string `json:"crdentialsSecretName,omitempty"`
 
 	// +kubebuilder:validation:Optional
 	Depth *int8 `json:"depth,omitempty"`
 
+	// Folder of the DAGs inside the repo, only supported with a single repo.
 	// +kubebuilder:validation:Optional
 	GitFolder string `json:"gitFolder,omitempty"`
 
+	// Name of the directory the repo is synced into, defaults to `repo-<index>`.
+	// Required with several repos.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// Extra git-sync flags, e.g. `--ref: main`. They take precedence over the flags derived from this spec.
 	// +kubebuilder:validation:Optional
 	GitSyncConf map[string]string `json:"gitSyncConf,omitempty"`
 
@@ -119,12 +154,15 @@ type ClusterConfigSpec struct {
 	// 	- adminUser.email
 	// 	- adminUser.password
 	// 	- connections.SecretKey	# Flask app secret key, eg: openssl rand -hex 30
-	// 	- connections.sqlalchemyDatabaseUri	# SQLAlchemy database URI, currently only supports postgresql in product container image
+	// 	- connections.sqlalchemyDatabaseUri	# SQLAlchemy database URI, only needed if metadataDatabase is not set
 	// 	- connections.celeryResultBackend	# Celery result backend, Only needed if using celery workers
 	// 	- connections.celeryBrokerUrl	# Celery broker URL, Only needed if using celery workers
 	// +kubebuilder:validation:Required
 	Credentials string `json:"credentialsSecret"`
 
+	// DAG repositories synced by git-sync. Every repo is synced into its own directory,
+	// which is added to PYTHONPATH. With several repos the DAGs folder is their common root,
+	// every repo must then be named and gitFolder can not be used.
 	// +kubebuilder:validation:Optional
 	DagsGitSync []DagsGitSyncSpec `json:"dagsGitSync,omitempty"`
 
@@ -138,6 +176,12 @@ type ClusterConfigSpec struct {
 	// +kubebuilder:validation:Type=boolean
 	LoadExamples bool `json:"loadExamples,omitempty"`
 
+	// The database airflow stores its metadata in. The operator builds the SQLAlchemy URI
+	// from it, so the password is never part of a URI stored in a secret.
+	// When not set, connections.sqlalchemyDatabaseUri of the credentials secret is used.
+	// +kubebuilder:validation:Optional
+	MetadataDatabase *MetadataDatabaseSpec `json:"metadataDatabase,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Enum=cluster-internal;external-unstable;external-stable
 	ListenerClass constants.ListenerClass `json:"listenerClass,omitempty"`
@@ -145,19 +189,69 @@ type ClusterConfigSpec struct {
 	// +kubebuilder:validation:Optional
 	VectorAggregatorConfigMapName string `json:"vectorAggregatorConfigMapName,omitempty"`
 
+	// Volumes added to the pods of every role, each item is a core/v1 Volume.
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:pruning:PreserveUnknownFields
-	// +kubebuilder:validation:EmbeddedResource
-	// +kubebuilder:validation:Type=object
 	Volumes []k8sruntime.RawExtension `json:"volumes,omitempty"`
 
+	// VolumeMounts added to the main container of every role, each item is a core/v1 VolumeMount
+	// referencing one of the volumes.
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:pruning:PreserveUnknownFields
-	// +kubebuilder:validation:EmbeddedResource
-	// +kubebuilder:validation:Type=object
 	VolumeMounts []k8sruntime.RawExtension `json:"volumeMounts,omitempty"`
 }
 
+// MetadataDatabaseSpec is the database airflow stores its metadata in, exactly one variant must be set.
+type MetadataDatabaseSpec struct {
+	// +kubebuilder:validation:Optional
+	Postgresql *PostgresqlSpec `json:"postgresql,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Mysql *MysqlSpec `json:"mysql,omitempty"`
+}
+
+// DatabaseConnectionSpec holds the settings shared by all database variants.
+type DatabaseConnectionSpec struct {
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+
+	// Defaults to the default port of the database.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_$-]+$`
+	Database string `json:"database"`
+
+	// Name of the secret holding the database credentials.
+	// The secret should contain the following keys:
+	// 	- username
+	// 	- password
+	// +kubebuilder:validation:Required
+	CredentialsSecret string `json:"credentialsSecret"`
+
+	// Secret class providing the CA certificate to verify the database server with.
+	// The secret-operator mounts it as ca.crt.
+	// +kubebuilder:validation:Optional
+	CASecretClass string `json:"caSecretClass,omitempty"`
+}
+
+type PostgresqlSpec struct {
+	DatabaseConnectionSpec `json:",inline"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
+	SSLMode string `json:"sslMode,omitempty"`
+}
+
+type MysqlSpec struct {
+	DatabaseConnectionSpec `json:",inline"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=DISABLED;PREFERRED;REQUIRED;VERIFY_CA;VERIFY_IDENTITY
+	SSLMode string `json:"sslMode,omitempty"`
+}
+
 type RoleGroupSpec struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=1
@@ -168,13 +262,250 @@ type RoleGroupSpec struct {
 
 type ConfigSpec struct {
 	*commonsv1alpha1.RoleGroupConfigSpec `json:",inline"`
+
+	// Thresholds of the probes of the main container, the probe handlers are chosen by role.
+	// +kubebuilder:validation:Optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+}
+
+type ProbesSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="!has(self.successThreshold) || self.successThreshold == 1",message="successThreshold must be 1"
+	Liveness *ProbeSpec `json:"liveness,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Readiness *ProbeSpec `json:"readiness,omitempty"`
+
+	// The startup probe holds back the other probes until airflow is up, e.g. while the database is migrated.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="!has(self.successThreshold) || self.successThreshold == 1",message="successThreshold must be 1"
+	Startup *ProbeSpec `json:"startup,omitempty"`
+}
+
+// ProbeSpec overrides the thresholds of a probe, unset fields keep the defaults of the role.
+type ProbeSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+
+	// Must be 1 for liveness and startup probes.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	SuccessThreshold *int32 `json:"successThreshold,omitempty"`
 }
 
 type CeleryExecutorsSpec struct {
-	RoleGroups                     map[string]RoleGroupSpec        `json:"roleGroups,omitempty"`
+	// The broker celery distributes tasks with. When not set, the broker url and result backend
+	// are read from connections.celeryBrokerUrl and connections.celeryResultBackend of the credentials secret.
+	// +kubebuilder:validation:Optional
+	Broker *CeleryBrokerSpec `json:"broker,omitempty"`
+
+	// The backend celery stores task results in, it defaults to the metadata database.
+	// Only used together with broker.
+	// +kubebuilder:validation:Optional
+	ResultBackend *CeleryResultBackendSpec `json:"resultBackend,omitempty"`
+
+	RoleGroups                     map[string]CeleryRoleGroupSpec  `json:"roleGroups,omitempty"`
 	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
-	Config                         *ConfigSpec                     `json:"config,omitempty"`
+	Config                         *CeleryConfigSpec               `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
+type CeleryRoleGroupSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=1
+	Replicas                       *int32            `json:"replicas"`
+	Config                         *CeleryConfigSpec `json:"config,omitempty"`
 	*commonsv1alpha1.OverridesSpec `json:",inline"`
+
+	// Scales the workers with KEDA, replicas is then left to the ScaledObject.
+	// +kubebuilder:validation:Optional
+	Autoscaling *CeleryAutoscalingSpec `json:"autoscaling,omitempty"`
+}
+
+// CeleryConfigSpec is the config of the celery workers, role groups can consume
+// their own queues, e.g. a gpu queue served by workers with gpu resources.
+type CeleryConfigSpec struct {
+	ConfigSpec `json:",inline"`
+
+	// Queues the workers consume, tasks are sent to a queue with the queue argument of the operator.
+	// Defaults to the default queue of airflow.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z0-9_.:-]+$`
+	Queues []string `json:"queues,omitempty"`
+
+	// Tasks a worker runs at the same time, airflow defaults to 16.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Concurrency *int32 `json:"concurrency,omitempty"`
+
+	// Lets celery grow and shrink the worker pool with the load, it takes precedence over concurrency.
+	// +kubebuilder:validation:Optional
+	Autoscale *CeleryWorkerAutoscaleSpec `json:"autoscale,omitempty"`
+}
+
+// CeleryWorkerAutoscaleSpec bounds the processes of the worker pool.
+// +kubebuilder:validation:XValidation:rule="self.min <= self.max",message="min must be less than or equal to max"
+type CeleryWorkerAutoscaleSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=0
+	Min int32 `json:"min"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	Max int32 `json:"max"`
+}
+
+type CeleryAutoscalingSource string
+
+const (
+	// CeleryAutoscalingSourceDatabase scales on the queued and running task instances of the metadata database
+	CeleryAutoscalingSourceDatabase CeleryAutoscalingSource = "Database"
+	// CeleryAutoscalingSourceBroker scales on the length of the celery queue in the broker
+	CeleryAutoscalingSourceBroker CeleryAutoscalingSource = "Broker"
+)
+
+// CeleryAutoscalingSpec renders a KEDA ScaledObject scaling the worker StatefulSet of a role group.
+// KEDA must be installed in the cluster.
+type CeleryAutoscalingSpec struct {
+	// Set it to 0 to scale the workers to zero while no task is queued.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// Database requires clusterConfig.metadataDatabase, Broker requires broker.
+	// KEDA connects with the sslMode of the database, it can not mount the CA of caSecretClass,
+	// so verify-ca and verify-full fall back to require for a database with a caSecretClass.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Database;Broker
+	// +kubebuilder:default=Database
+	Source CeleryAutoscalingSource `json:"source,omitempty"`
+
+	// Tasks a worker takes, it should match the worker concurrency.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=16
+	TasksPerReplica *int32 `json:"tasksPerReplica,omitempty"`
+
+	// Seconds between two checks of the load.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	PollingInterval *int32 `json:"pollingInterval,omitempty"`
+
+	// Seconds without load before the workers are scaled to zero.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	CooldownPeriod *int32 `json:"cooldownPeriod,omitempty"`
+}
+
+// CeleryBrokerSpec is the celery broker, exactly one variant must be set.
+type CeleryBrokerSpec struct {
+	// +kubebuilder:validation:Optional
+	Redis *RedisSpec `json:"redis,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	RedisSentinel *RedisSentinelSpec `json:"redisSentinel,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Rabbitmq *RabbitmqSpec `json:"rabbitmq,omitempty"`
+}
+
+// CeleryResultBackendSpec is the celery result backend, the metadata database is used when no variant is set.
+type CeleryResultBackendSpec struct {
+	// +kubebuilder:validation:Optional
+	Redis *RedisSpec `json:"redis,omitempty"`
+}
+
+// BrokerTLSSpec enables TLS for the connection to a broker.
+type BrokerTLSSpec struct {
+	// Secret class providing the CA certificate to verify the server with.
+	// The secret-operator mounts it as ca.crt.
+	// +kubebuilder:validation:Optional
+	CASecretClass string `json:"caSecretClass,omitempty"`
+}
+
+// BrokerCredentialsSpec holds the settings shared by all broker variants.
+type BrokerCredentialsSpec struct {
+	// Name of the secret holding the credentials, if the broker requires authentication.
+	// The secret should contain the following keys:
+	// 	- username	# optional for redis
+	// 	- password
+	// +kubebuilder:validation:Optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// TLS is enabled when set.
+	// +kubebuilder:validation:Optional
+	TLS *BrokerTLSSpec `json:"tls,omitempty"`
+}
+
+type RedisSpec struct {
+	BrokerCredentialsSpec `json:",inline"`
+
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=6379
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=0
+	// +kubebuilder:validation:Minimum=0
+	DB int32 `json:"db,omitempty"`
+}
+
+type RedisSentinelSpec struct {
+	BrokerCredentialsSpec `json:",inline"`
+
+	// Sentinel addresses as host:port.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Hosts []string `json:"hosts"`
+
+	// Name of the redis master monitored by the sentinels.
+	// +kubebuilder:validation:Required
+	MasterName string `json:"masterName"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=0
+	// +kubebuilder:validation:Minimum=0
+	DB int32 `json:"db,omitempty"`
+}
+
+type RabbitmqSpec struct {
+	BrokerCredentialsSpec `json:",inline"`
+
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+
+	// Defaults to 5672, or 5671 with TLS.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="/"
+	Vhost string `json:"vhost,omitempty"`
 }
 
 type KubernetesExecutorsSpec struct {
@@ -191,6 +522,22 @@ type SchedulersSpec struct {
 	*commonsv1alpha1.OverridesSpec `json:",inline"`
 }
 
+// TriggerersSpec runs the triggerer, which resumes deferrable operators and sensors.
+type TriggerersSpec struct {
+	RoleGroups                     map[string]RoleGroupSpec        `json:"roleGroups,omitempty"`
+	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
+	Config                         *ConfigSpec                     `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
+// DagProcessorsSpec runs the standalone dag processor, which parses DAGs outside the scheduler.
+type DagProcessorsSpec struct {
+	RoleGroups                     map[string]RoleGroupSpec        `json:"roleGroups,omitempty"`
+	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
+	Config                         *ConfigSpec                     `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
 type WebserversSpec struct {
 	RoleGroups                     map[string]RoleGroupSpec        `json:"roleGroups,omitempty"`
 	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
@@ -198,6 +545,23 @@ type WebserversSpec struct {
 	*commonsv1alpha1.OverridesSpec `json:",inline"`
 }
 
+// FlowerSpec runs the celery flower UI, it monitors the workers and queues of the celery executors.
+type FlowerSpec struct {
+	// Secret with the username and password keys flower checks with basic auth.
+	// +kubebuilder:validation:Required
+	CredentialsSecret string `json:"credentialsSecret"`
+
+	// Exposes flower, it defaults to clusterConfig.listenerClass.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=cluster-internal;external-unstable;external-stable
+	ListenerClass constants.ListenerClass `json:"listenerClass,omitempty"`
+
+	RoleGroups                     map[string]RoleGroupSpec        `json:"roleGroups,omitempty"`
+	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
+	Config                         *ConfigSpec                     `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
 // AirflowClusterSpec defines the desired state of AirflowCluster.
 type AirflowClusterSpec struct {
 	// +kubebuilder:validation:Optional
@@ -221,14 +585,137 @@ type AirflowClusterSpec struct {
 
 	// +kubebuilder:validation:Optional
 	Webservers *WebserversSpec `json:"webservers,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Triggerers *TriggerersSpec `json:"triggerers,omitempty"`
+
+	// When set, DAGs are parsed by the dag processors instead of the schedulers.
+	// +kubebuilder:validation:Optional
+	DagProcessors *DagProcessorsSpec `json:"dagProcessors,omitempty"`
+
+	// Requires celeryExecutors.
+	// +kubebuilder:validation:Optional
+	Flower *FlowerSpec `json:"flower,omitempty"`
+}
+
+const (
+	ConditionTypeAvailable            = "Available"
+	ConditionTypeProgressing          = "Progressing"
+	ConditionTypeDegraded             = "Degraded"
+	ConditionTypeReconciliationPaused = "ReconciliationPaused"
+)
+
+// RoleGroupStatus is the observed state of the StatefulSet backing a role group.
+type RoleGroupStatus struct {
+	// +kubebuilder:validation:Required
+	Role string `json:"role"`
+
+	// +kubebuilder:validation:Required
+	RoleGroup string `json:"roleGroup"`
+
+	// Desired replicas of the role group StatefulSet.
+	// +kubebuilder:validation:Optional
+	Replicas int32 `json:"replicas"`
+
+	// +kubebuilder:validation:Optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+}
+
+// DagsGitSyncStatus is the sync state of a DAG repository across the pods of the cluster.
+type DagsGitSyncStatus struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Required
+	Repo string `json:"repo"`
+
+	// The folder of the DAGs in the pods.
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+
+	// Pods whose git-sync containers are running.
+	// +kubebuilder:validation:Optional
+	SyncedPods int32 `json:"syncedPods"`
+
+	// Pods whose git-sync containers failed.
+	// +kubebuilder:validation:Optional
+	FailedPods int32 `json:"failedPods"`
+
+	// The last error reported by git-sync, if any.
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+type DatabaseMigrationPhase string
+
+const (
+	DatabaseMigrationPending   DatabaseMigrationPhase = "Pending"
+	DatabaseMigrationRunning   DatabaseMigrationPhase = "Running"
+	DatabaseMigrationSucceeded DatabaseMigrationPhase = "Succeeded"
+	DatabaseMigrationFailed    DatabaseMigrationPhase = "Failed"
+)
+
+// DatabaseMigrationStatus is the state of the job migrating the metadata database to the product version.
+// The job is created again when the database or credentials change. A failed job is kept,
+// deleting it runs the migration again.
+type DatabaseMigrationStatus struct {
+	// +kubebuilder:validation:Required
+	ProductVersion string `json:"productVersion"`
+
+	// +kubebuilder:validation:Required
+	JobName string `json:"jobName"`
+
+	// +kubebuilder:validation:Required
+	Phase DatabaseMigrationPhase `json:"phase"`
+
+	// The reason the job failed, if any.
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
 }
 
 // AirflowClusterStatus defines the observed state of AirflowCluster.
 type AirflowClusterStatus struct {
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The generation of the AirflowCluster that was last reconciled.
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	RoleGroups []RoleGroupStatus `json:"roleGroups,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	DagsGitSync []DagsGitSyncStatus `json:"dagsGitSync,omitempty"`
+
+	// Workloads are only rolled out after the migration succeeded.
+	// +kubebuilder:validation:Optional
+	DatabaseMigration *DatabaseMigrationStatus `json:"databaseMigration,omitempty"`
+
+	// The addresses the webserver role groups are exposed on, also published
+	// in the discovery ConfigMap named after the cluster.
+	// +kubebuilder:validation:Optional
+	Webservers []WebserverAddressStatus `json:"webservers,omitempty"`
+}
+
+// WebserverAddressStatus is the address of a webserver role group. With a listener class
+// it is the address of the Listener, otherwise the in-cluster address of the Service.
+type WebserverAddressStatus struct {
+	RoleGroup string `json:"roleGroup"`
+	Address   string `json:"address"`
+	Port      int32  `json:"port"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Available",type="string",JSONPath=".status.conditions[?(@.type==\"Available\")].status"
+// +kubebuilder:printcolumn:name="Progressing",type="string",JSONPath=".status.conditions[?(@.type==\"Progressing\")].status"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+// +kubebuilder:printcolumn:name="Paused",type="string",JSONPath=".status.conditions[?(@.type==\"ReconciliationPaused\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AirflowCluster is the Schema for the airflowclusters API.
 type AirflowCluster struct {
@@ -23,6 +23,7 @@ package v1alpha1
 import (
 	authenticationv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
 	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -32,7 +33,7 @@ func (in *AirflowCluster) DeepCopyInto(out *AirflowCluster) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AirflowCluster.
@@ -123,6 +124,21 @@ func (in *AirflowClusterSpec) DeepCopyInto(out *AirflowClusterSpec) {
 		*out = new(WebserversSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Triggerers != nil {
+		in, out := &in.Triggerers, &out.Triggerers
+		*out = new(TriggerersSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DagProcessors != nil {
+		in, out := &in.DagProcessors, &out.DagProcessors
+		*out = new(DagProcessorsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Flower != nil {
+		in, out := &in.Flower, &out.Flower
+		*out = new(FlowerSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AirflowClusterSpec.
@@ -138,6 +154,33 @@ func (in *AirflowClusterSpec) DeepCopy() *AirflowClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AirflowClusterStatus) DeepCopyInto(out *AirflowClusterStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make([]RoleGroupStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.DagsGitSync != nil {
+		in, out := &in.DagsGitSync, &out.DagsGitSync
+		*out = make([]DagsGitSyncStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.DatabaseMigration != nil {
+		in, out := &in.DatabaseMigration, &out.DatabaseMigration
+		*out = new(DatabaseMigrationStatus)
+		**out = **in
+	}
+	if in.Webservers != nil {
+		in, out := &in.Webservers, &out.Webservers
+		*out = make([]WebserverAddressStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AirflowClusterStatus.
@@ -158,6 +201,22 @@ func (in *AuthenticationSpec) DeepCopyInto(out *AuthenticationSpec) {
 		*out = new(authenticationv1alpha1.OidcSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RoleMapping != nil {
+		in, out := &in.RoleMapping, &out.RoleMapping
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthenticationSpec.
@@ -170,12 +229,153 @@ func (in *AuthenticationSpec) DeepCopy() *AuthenticationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BrokerCredentialsSpec) DeepCopyInto(out *BrokerCredentialsSpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(BrokerTLSSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BrokerCredentialsSpec.
+func (in *BrokerCredentialsSpec) DeepCopy() *BrokerCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BrokerCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BrokerTLSSpec) DeepCopyInto(out *BrokerTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BrokerTLSSpec.
+func (in *BrokerTLSSpec) DeepCopy() *BrokerTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BrokerTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryAutoscalingSpec) DeepCopyInto(out *CeleryAutoscalingSpec) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TasksPerReplica != nil {
+		in, out := &in.TasksPerReplica, &out.TasksPerReplica
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PollingInterval != nil {
+		in, out := &in.PollingInterval, &out.PollingInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CooldownPeriod != nil {
+		in, out := &in.CooldownPeriod, &out.CooldownPeriod
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryAutoscalingSpec.
+func (in *CeleryAutoscalingSpec) DeepCopy() *CeleryAutoscalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryAutoscalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryBrokerSpec) DeepCopyInto(out *CeleryBrokerSpec) {
+	*out = *in
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(RedisSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RedisSentinel != nil {
+		in, out := &in.RedisSentinel, &out.RedisSentinel
+		*out = new(RedisSentinelSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rabbitmq != nil {
+		in, out := &in.Rabbitmq, &out.Rabbitmq
+		*out = new(RabbitmqSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryBrokerSpec.
+func (in *CeleryBrokerSpec) DeepCopy() *CeleryBrokerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryBrokerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryConfigSpec) DeepCopyInto(out *CeleryConfigSpec) {
+	*out = *in
+	in.ConfigSpec.DeepCopyInto(&out.ConfigSpec)
+	if in.Queues != nil {
+		in, out := &in.Queues, &out.Queues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Autoscale != nil {
+		in, out := &in.Autoscale, &out.Autoscale
+		*out = new(CeleryWorkerAutoscaleSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryConfigSpec.
+func (in *CeleryConfigSpec) DeepCopy() *CeleryConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CeleryExecutorsSpec) DeepCopyInto(out *CeleryExecutorsSpec) {
 	*out = *in
+	if in.Broker != nil {
+		in, out := &in.Broker, &out.Broker
+		*out = new(CeleryBrokerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResultBackend != nil {
+		in, out := &in.ResultBackend, &out.ResultBackend
+		*out = new(CeleryResultBackendSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RoleGroups != nil {
 		in, out := &in.RoleGroups, &out.RoleGroups
-		*out = make(map[string]RoleGroupSpec, len(*in))
+		*out = make(map[string]CeleryRoleGroupSpec, len(*in))
 		for key, val := range *in {
 			(*out)[key] = *val.DeepCopy()
 		}
@@ -187,7 +387,7 @@ func (in *CeleryExecutorsSpec) DeepCopyInto(out *CeleryExecutorsSpec) {
 	}
 	if in.Config != nil {
 		in, out := &in.Config, &out.Config
-		*out = new(ConfigSpec)
+		*out = new(CeleryConfigSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.OverridesSpec != nil {
@@ -207,6 +407,76 @@ func (in *CeleryExecutorsSpec) DeepCopy() *CeleryExecutorsSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryResultBackendSpec) DeepCopyInto(out *CeleryResultBackendSpec) {
+	*out = *in
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(RedisSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryResultBackendSpec.
+func (in *CeleryResultBackendSpec) DeepCopy() *CeleryResultBackendSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryResultBackendSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryRoleGroupSpec) DeepCopyInto(out *CeleryRoleGroupSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(CeleryConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(CeleryAutoscalingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryRoleGroupSpec.
+func (in *CeleryRoleGroupSpec) DeepCopy() *CeleryRoleGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryRoleGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CeleryWorkerAutoscaleSpec) DeepCopyInto(out *CeleryWorkerAutoscaleSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CeleryWorkerAutoscaleSpec.
+func (in *CeleryWorkerAutoscaleSpec) DeepCopy() *CeleryWorkerAutoscaleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CeleryWorkerAutoscaleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterConfigSpec) DeepCopyInto(out *ClusterConfigSpec) {
 	*out = *in
@@ -224,6 +494,11 @@ func (in *ClusterConfigSpec) DeepCopyInto(out *ClusterConfigSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MetadataDatabase != nil {
+		in, out := &in.MetadataDatabase, &out.MetadataDatabase
+		*out = new(MetadataDatabaseSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]runtime.RawExtension, len(*in))
@@ -258,6 +533,11 @@ func (in *ConfigSpec) DeepCopyInto(out *ConfigSpec) {
 		*out = new(commonsv1alpha1.RoleGroupConfigSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigSpec.
@@ -270,6 +550,43 @@ func (in *ConfigSpec) DeepCopy() *ConfigSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DagProcessorsSpec) DeepCopyInto(out *DagProcessorsSpec) {
+	*out = *in
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make(map[string]RoleGroupSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.RoleConfig != nil {
+		in, out := &in.RoleConfig, &out.RoleConfig
+		*out = new(commonsv1alpha1.RoleConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DagProcessorsSpec.
+func (in *DagProcessorsSpec) DeepCopy() *DagProcessorsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DagProcessorsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DagsGitSyncSpec) DeepCopyInto(out *DagsGitSyncSpec) {
 	*out = *in
@@ -302,6 +619,88 @@ func (in *DagsGitSyncSpec) DeepCopy() *DagsGitSyncSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DagsGitSyncStatus) DeepCopyInto(out *DagsGitSyncStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DagsGitSyncStatus.
+func (in *DagsGitSyncStatus) DeepCopy() *DagsGitSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DagsGitSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatabaseConnectionSpec) DeepCopyInto(out *DatabaseConnectionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseConnectionSpec.
+func (in *DatabaseConnectionSpec) DeepCopy() *DatabaseConnectionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseConnectionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatabaseMigrationStatus) DeepCopyInto(out *DatabaseMigrationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseMigrationStatus.
+func (in *DatabaseMigrationStatus) DeepCopy() *DatabaseMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlowerSpec) DeepCopyInto(out *FlowerSpec) {
+	*out = *in
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make(map[string]RoleGroupSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.RoleConfig != nil {
+		in, out := &in.RoleConfig, &out.RoleConfig
+		*out = new(commonsv1alpha1.RoleConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlowerSpec.
+func (in *FlowerSpec) DeepCopy() *FlowerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FlowerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 	*out = *in
@@ -352,6 +751,186 @@ func (in *KubernetesExecutorsSpec) DeepCopy() *KubernetesExecutorsSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataDatabaseSpec) DeepCopyInto(out *MetadataDatabaseSpec) {
+	*out = *in
+	if in.Postgresql != nil {
+		in, out := &in.Postgresql, &out.Postgresql
+		*out = new(PostgresqlSpec)
+		**out = **in
+	}
+	if in.Mysql != nil {
+		in, out := &in.Mysql, &out.Mysql
+		*out = new(MysqlSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataDatabaseSpec.
+func (in *MetadataDatabaseSpec) DeepCopy() *MetadataDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MysqlSpec) DeepCopyInto(out *MysqlSpec) {
+	*out = *in
+	out.DatabaseConnectionSpec = in.DatabaseConnectionSpec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MysqlSpec.
+func (in *MysqlSpec) DeepCopy() *MysqlSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MysqlSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresqlSpec) DeepCopyInto(out *PostgresqlSpec) {
+	*out = *in
+	out.DatabaseConnectionSpec = in.DatabaseConnectionSpec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresqlSpec.
+func (in *PostgresqlSpec) DeepCopy() *PostgresqlSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresqlSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SuccessThreshold != nil {
+		in, out := &in.SuccessThreshold, &out.SuccessThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSpec.
+func (in *ProbeSpec) DeepCopy() *ProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesSpec) DeepCopyInto(out *ProbesSpec) {
+	*out = *in
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesSpec.
+func (in *ProbesSpec) DeepCopy() *ProbesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RabbitmqSpec) DeepCopyInto(out *RabbitmqSpec) {
+	*out = *in
+	in.BrokerCredentialsSpec.DeepCopyInto(&out.BrokerCredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RabbitmqSpec.
+func (in *RabbitmqSpec) DeepCopy() *RabbitmqSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RabbitmqSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisSentinelSpec) DeepCopyInto(out *RedisSentinelSpec) {
+	*out = *in
+	in.BrokerCredentialsSpec.DeepCopyInto(&out.BrokerCredentialsSpec)
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisSentinelSpec.
+func (in *RedisSentinelSpec) DeepCopy() *RedisSentinelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisSentinelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisSpec) DeepCopyInto(out *RedisSpec) {
+	*out = *in
+	in.BrokerCredentialsSpec.DeepCopyInto(&out.BrokerCredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisSpec.
+func (in *RedisSpec) DeepCopy() *RedisSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RoleGroupSpec) DeepCopyInto(out *RoleGroupSpec) {
 	*out = *in
@@ -382,6 +961,21 @@ func (in *RoleGroupSpec) DeepCopy() *RoleGroupSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleGroupStatus) DeepCopyInto(out *RoleGroupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleGroupStatus.
+func (in *RoleGroupStatus) DeepCopy() *RoleGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SchedulersSpec) DeepCopyInto(out *SchedulersSpec) {
 	*out = *in
@@ -419,6 +1013,58 @@ func (in *SchedulersSpec) DeepCopy() *SchedulersSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TriggerersSpec) DeepCopyInto(out *TriggerersSpec) {
+	*out = *in
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make(map[string]RoleGroupSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.RoleConfig != nil {
+		in, out := &in.RoleConfig, &out.RoleConfig
+		*out = new(commonsv1alpha1.RoleConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TriggerersSpec.
+func (in *TriggerersSpec) DeepCopy() *TriggerersSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TriggerersSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebserverAddressStatus) DeepCopyInto(out *WebserverAddressStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebserverAddressStatus.
+func (in *WebserverAddressStatus) DeepCopy() *WebserverAddressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WebserverAddressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebserversSpec) DeepCopyInto(out *WebserversSpec) {
 	*out = *in
@@ -21,6 +21,8 @@ import (
 
 	"github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -44,9 +46,18 @@ type AirflowClusterReconciler struct {
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get;list
+// +kubebuilder:rbac:groups=core,resources=pods/exec,verbs=create;get
+// +kubebuilder:rbac:groups=core,resources=events,verbs=list
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=authentication.kubedoop.dev,resources=authenticationclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=listeners.kubedoop.dev,resources=listeners,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects;triggerauthentications,verbs=get;list;watch;create;update;patch;delete
 
 func (r *AirflowClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -78,17 +89,32 @@ func (r *AirflowClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 	reconciler := NewClusterReconciler(resourceClient, clusterInfo, &instance.Spec)
 
-	if err := reconciler.RegisterResource(ctx); err != nil {
+	result, err := r.reconcile(ctx, reconciler)
+
+	if statusErr := r.updateStatus(ctx, instance, clusterInfo, result, err); statusErr != nil {
+		logger.Error(statusErr, "Failed to update AirflowCluster status", "namespace", instance.Namespace, "name", instance.Name)
+		if err == nil {
+			return ctrl.Result{}, statusErr
+		}
+	}
+
+	return result, err
+}
+
+func (r *AirflowClusterReconciler) reconcile(ctx context.Context, cluster *ClusterReconciler) (ctrl.Result, error) {
+	if err := cluster.RegisterResource(ctx); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return reconciler.Run(ctx)
+	return cluster.Run(ctx)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AirflowClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&airflowv1alpha1.AirflowCluster{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
 		Named("airflowcluster").
 		Complete(r)
 }
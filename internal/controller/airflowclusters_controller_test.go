@@ -22,6 +22,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -64,7 +65,7 @@ var _ = Describe("AirflowCluster Controller", func() {
 							},
 						},
 						CeleryExecutors: &airflowv1alpha1.CeleryExecutorsSpec{
-							RoleGroups: map[string]airflowv1alpha1.RoleGroupSpec{
+							RoleGroups: map[string]airflowv1alpha1.CeleryRoleGroupSpec{
 								"default": {
 									Replicas: ptr.To[int32](1),
 								},
@@ -103,8 +104,23 @@ var _ = Describe("AirflowCluster Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the status of the reconciled resource")
+			resource := &airflowv1alpha1.AirflowCluster{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, airflowv1alpha1.ConditionTypeDegraded)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, airflowv1alpha1.ConditionTypeReconciliationPaused)).To(BeTrue())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, airflowv1alpha1.ConditionTypeAvailable)).NotTo(BeNil())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, airflowv1alpha1.ConditionTypeProgressing)).NotTo(BeNil())
+			Expect(resource.Status.RoleGroups).To(HaveLen(3))
+
+			By("Checking the workloads wait for the database migration")
+			Expect(resource.Status.DatabaseMigration).NotTo(BeNil())
+			Expect(resource.Status.DatabaseMigration.Phase).To(Equal(airflowv1alpha1.DatabaseMigrationPending))
+
+			By("Checking the webserver address is published")
+			Expect(resource.Status.Webservers).NotTo(BeEmpty())
 		})
 	})
 })
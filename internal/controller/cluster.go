@@ -2,12 +2,16 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/zncdatadev/operator-go/pkg/builder"
 	"github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
 	"github.com/zncdatadev/operator-go/pkg/util"
+	"k8s.io/utils/ptr"
 
 	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
 	"github.com/zncdatadev/airflow-operator/internal/controller/role"
 	airflowversion "github.com/zncdatadev/airflow-operator/internal/util/version"
 )
@@ -36,76 +40,240 @@ func NewClusterReconciler(
 }
 
 func (r *ClusterReconciler) GetImage() *util.Image {
+	return getImage(r.Spec.Image)
+}
+
+func getImage(imageSpec *airflowv1alpha1.ImageSpec) *util.Image {
+	if imageSpec == nil {
+		imageSpec = &airflowv1alpha1.ImageSpec{}
+	}
+
+	productVersion := imageSpec.ProductVersion
+	if productVersion == "" {
+		productVersion = airflowv1alpha1.DefaultProductVersion
+	}
+
 	image := util.NewImage(
 		airflowv1alpha1.DefaultProductName,
 		airflowversion.BuildVersion,
-		airflowv1alpha1.DefaultProductVersion,
+		productVersion,
 		func(options *util.ImageOptions) {
-			options.Custom = r.Spec.Image.Custom
-			options.Repo = r.Spec.Image.Repo
-			options.PullPolicy = r.Spec.Image.PullPolicy
+			options.Custom = imageSpec.Custom
+			options.Repo = imageSpec.Repo
+			options.PullPolicy = imageSpec.PullPolicy
 		},
 	)
 
-	if r.Spec.Image.KubedoopVersion != "" {
-		image.KubedoopVersion = r.Spec.Image.KubedoopVersion
+	if imageSpec.KubedoopVersion != "" {
+		image.KubedoopVersion = imageSpec.KubedoopVersion
 	}
 
 	return image
 }
 
-func (r *ClusterReconciler) RegisterResource(ctx context.Context) error {
+// getDagProcessors returns the dag processors of the cluster. Airflow 3 no longer parses
+// DAGs in the scheduler, so a single dag processor is run when the spec has none.
+func getDagProcessors(spec *airflowv1alpha1.AirflowClusterSpec) *airflowv1alpha1.DagProcessorsSpec {
+	if spec.DagProcessors == nil && common.IsAirflow3(getImage(spec.Image).ProductVersion) {
+		return &airflowv1alpha1.DagProcessorsSpec{
+			RoleGroups: map[string]airflowv1alpha1.RoleGroupSpec{
+				"default": {Replicas: ptr.To[int32](1)},
+			},
+		}
+	}
+	return spec.DagProcessors
+}
+
+// GetExecutor returns the executor used by schedulers and webservers,
+// kubernetes executor takes effect when kubernetesExecutors is set.
+func (r *ClusterReconciler) GetExecutor() (common.ExecutorType, error) {
+	if r.Spec.KubernetesExecutors != nil {
+		if r.Spec.CeleryExecutors != nil {
+			return 0, fmt.Errorf("celeryExecutors and kubernetesExecutors can not be set at the same time")
+		}
+		return common.KubernetesExecutor, nil
+	}
+	return common.CeleryExecutor, nil
+}
 
-	celery := role.NewCeleryExecutorsReconciler(
+func (r *ClusterReconciler) RegisterResource(ctx context.Context) error {
+	executor, err := r.GetExecutor()
+	if err != nil {
+		return err
+	}
+	// the migration job is registered first, it holds back the roles until the database is migrated
+	migration := common.NewMigrationJobReconciler(
 		r.Client,
-		r.IsStopped(),
 		r.ClusterConfig,
-		reconciler.RoleInfo{
-			ClusterInfo: r.ClusterInfo,
-			RoleName:    string(airflowv1alpha1.CeleryExecutorsRoleName),
-		},
 		r.GetImage(),
-		r.Spec.CeleryExecutors,
+		func(o *builder.Options) {
+			o.ClusterName = r.ClusterInfo.GetClusterName()
+			o.Labels = r.ClusterInfo.GetLabels()
+			o.Annotations = r.ClusterInfo.GetAnnotations()
+		},
 	)
-	if err := celery.RegisterResources(ctx); err != nil {
-		return err
+	r.AddResource(migration)
+
+	// schedulers and webservers talk to the celery broker too
+	celery := common.NewCeleryBroker(r.ClusterConfig, r.Spec.CeleryExecutors)
+
+	if r.Spec.CeleryExecutors != nil {
+		celeryExecutors := role.NewCeleryExecutorsReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.CeleryExecutorsRoleName),
+			},
+			r.GetImage(),
+			r.Spec.CeleryExecutors,
+		)
+		if err := celeryExecutors.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(celeryExecutors)
 	}
 
-	r.AddResource(celery)
+	if r.Spec.KubernetesExecutors != nil {
+		kubernetes := role.NewKubernetesExecutorsReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.KubernetesExecutorsRoleName),
+			},
+			r.GetImage(),
+			r.Spec.KubernetesExecutors,
+		)
+		if err := kubernetes.RegisterResources(ctx); err != nil {
+			return err
+		}
 
-	schedulers := role.NewSchedulersReconciler(
-		r.Client,
-		r.IsStopped(),
-		r.ClusterConfig,
-		reconciler.RoleInfo{
-			ClusterInfo: r.ClusterInfo,
-			RoleName:    string(airflowv1alpha1.SchedulersRoleName),
-		},
-		r.GetImage(),
-		r.Spec.Schedulers,
-	)
-	if err := schedulers.RegisterResources(ctx); err != nil {
-		return err
+		r.AddResource(kubernetes)
+	}
+
+	// schedulers and webservers are defaulted by the webhook, which may be disabled
+	if r.Spec.Schedulers != nil {
+		schedulers := role.NewSchedulersReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.SchedulersRoleName),
+			},
+			r.GetImage(),
+			executor,
+			celery,
+			r.Spec.Schedulers,
+		)
+		// airflow 3 has no standalone_dag_processor option, the dag processor always runs on its own
+		schedulers.StandaloneDagProcessor = r.Spec.DagProcessors != nil && !common.IsAirflow3(r.GetImage().ProductVersion)
+		if err := schedulers.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(schedulers)
+	}
+
+	if r.Spec.Webservers != nil {
+		webservers := role.NewWebserversReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.WebserversRoleName),
+			},
+			r.GetImage(),
+			executor,
+			celery,
+			r.Spec.Webservers,
+		)
+		if err := webservers.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(webservers)
+	}
+
+	if r.Spec.Triggerers != nil {
+		triggerers := role.NewTriggerersReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.TriggerersRoleName),
+			},
+			r.GetImage(),
+			executor,
+			celery,
+			r.Spec.Triggerers,
+		)
+		if err := triggerers.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(triggerers)
+	}
+
+	if dagProcessorsSpec := getDagProcessors(r.Spec); dagProcessorsSpec != nil {
+		dagProcessors := role.NewDagProcessorsReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.DagProcessorsRoleName),
+			},
+			r.GetImage(),
+			executor,
+			celery,
+			dagProcessorsSpec,
+		)
+		if err := dagProcessors.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(dagProcessors)
 	}
 
-	r.AddResource(schedulers)
+	if r.Spec.Flower != nil && r.Spec.CeleryExecutors != nil {
+		flower := role.NewFlowerReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    string(airflowv1alpha1.FlowerRoleName),
+			},
+			r.GetImage(),
+			celery,
+			r.Spec.Flower,
+		)
+		if err := flower.RegisterResources(ctx); err != nil {
+			return err
+		}
 
-	webservers := role.NewWebserversReconciler(
+		r.AddResource(flower)
+	}
+
+	// the discovery ConfigMap is registered last, the listeners exist once the webservers are rolled out
+	discovery := common.NewDiscoveryReconciler(
 		r.Client,
-		r.IsStopped(),
 		r.ClusterConfig,
-		reconciler.RoleInfo{
-			ClusterInfo: r.ClusterInfo,
-			RoleName:    string(airflowv1alpha1.WebserversRoleName),
+		getRoleGroupNames(r.Spec)[airflowv1alpha1.WebserversRoleName],
+		func(o *builder.Options) {
+			o.ClusterName = r.ClusterInfo.GetClusterName()
+			o.Labels = r.ClusterInfo.GetLabels()
+			o.Annotations = r.ClusterInfo.GetAnnotations()
 		},
-		r.GetImage(),
-		r.Spec.Webservers,
 	)
-	if err := webservers.RegisterResources(ctx); err != nil {
-		return err
-	}
-
-	r.AddResource(webservers)
+	r.AddResource(discovery)
 
 	return nil
 }
@@ -3,12 +3,15 @@ package commons
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/url"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 
 	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
 	"github.com/zncdatadev/operator-go/pkg/builder"
 	"github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/config"
@@ -40,11 +43,31 @@ const (
 	DefaultLDAPFieldUid       = "uid"
 )
 
+const (
+	DefaultLDAPPort  = 389
+	DefaultLDAPSPort = 636
+)
+
+const (
+	// LdapVolumeNamePrefix prefixes the bind credentials and CA volumes of ldap authentication
+	LdapVolumeNamePrefix = "ldap-"
+	// OidcCAVolumeNamePrefix prefixes the CA volumes of oidc authentication
+	OidcCAVolumeNamePrefix = "oidc-ca-"
+)
+
+// DefaultOidcProviderName names the provider of a cluster with a single OIDC authentication. FAB redirects
+// to /oauth-authorized/<name>, the name is kept so the redirect URI registered at the issuer stays valid.
+const DefaultOidcProviderName = "keycloak"
+
 const (
 	EnvKeyOidcClientId     = "OIDC_CLIENT_ID"
 	EnvKeyOidcClientSecret = "OIDC_CLIENT_SECRET"
+	EnvKeyRequestsCABundle = "REQUESTS_CA_BUNDLE"
 )
 
+// OidcCABundleFile is the CA bundle requests trusts, it holds the system CAs and the CAs of every OIDC provider.
+var OidcCABundleFile = path.Join(AirflowHome, "oidc-ca-bundle.crt")
+
 var authLogger = ctrl.Log.WithName("authenticator")
 
 type Authenticator interface {
@@ -71,6 +94,8 @@ type Authentication struct {
 	syncRolesAt          *string
 	userRegistration     *bool
 	userRegistrationRole *string
+	// roleMapping maps directory groups to Airflow roles
+	roleMapping map[string][]string
 }
 
 func containsAuthType(authTypes []AuthenticatorType, authType AuthenticatorType) bool {
@@ -91,6 +116,7 @@ func NewAuthentication(
 	var syncRolesAt *string
 	var userRegistration *bool
 	var userRegistrationRole *string
+	roleMapping := make(map[string][]string)
 	for _, auth := range auths {
 		provider, err := GetAuthProvider(ctx, client, auth.AuthenticationClass)
 		if err != nil {
@@ -98,10 +124,18 @@ func NewAuthentication(
 		}
 
 		if provider.OIDC != nil && containsAuthType(AirflowSupportAuthTypes, AuthenticatorTypeOIDC) {
-			oidcAuth := &oidcAuthenticator{config: auth.Oidc, provider: provider.OIDC}
+			if auth.Oidc == nil {
+				return nil, fmt.Errorf("oidc client credentials are required by authentication class: %s", auth.AuthenticationClass)
+			}
+			oidcAuth := &oidcAuthenticator{
+				name:       auth.AuthenticationClass,
+				rolesClaim: auth.RolesClaim,
+				config:     auth.Oidc,
+				provider:   provider.OIDC,
+			}
 			authenticators[AuthenticatorTypeOIDC] = append(authenticators[AuthenticatorTypeOIDC], oidcAuth)
 		} else if provider.LDAP != nil && containsAuthType(AirflowSupportAuthTypes, AuthenticatorTypeLDAP) {
-			ldapAuth := &ldapAuthenticator{provider: provider.LDAP}
+			ldapAuth := &ldapAuthenticator{provider: provider.LDAP, startTLS: auth.LdapStartTLS}
 			authenticators[AuthenticatorTypeLDAP] = append(authenticators[AuthenticatorTypeLDAP], ldapAuth)
 		} else {
 			return nil, fmt.Errorf("unsupported authentication provider: %s", auth.AuthenticationClass)
@@ -122,6 +156,18 @@ func NewAuthentication(
 		} else if *userRegistrationRole != auth.UserRegistrationRole {
 			return nil, fmt.Errorf("userRegistrationRole must be the same for all authentication providers")
 		}
+		for group, roles := range auth.RoleMapping {
+			for _, role := range roles {
+				if !slices.Contains(roleMapping[group], role) {
+					roleMapping[group] = append(roleMapping[group], role)
+				}
+			}
+		}
+	}
+
+	// a single provider keeps the name and env vars it had before several providers were supported
+	if oidcAuthenticators := authenticators[AuthenticatorTypeOIDC]; len(oidcAuthenticators) == 1 {
+		oidcAuthenticators[0].(*oidcAuthenticator).single = true
 	}
 
 	return &Authentication{
@@ -129,6 +175,7 @@ func NewAuthentication(
 		syncRolesAt:          syncRolesAt,
 		userRegistration:     userRegistration,
 		userRegistrationRole: userRegistrationRole,
+		roleMapping:          roleMapping,
 	}, nil
 }
 
@@ -141,23 +188,64 @@ func (a *Authentication) getTotalAuthenticatorCount() int {
 	return total
 }
 
+// getAuthenticators returns the authenticators in a stable order, so the pod template
+// does not change between reconciles.
+func (a *Authentication) getAuthenticators() []Authenticator {
+	authenticators := make([]Authenticator, 0, a.getTotalAuthenticatorCount())
+	for _, authType := range AirflowSupportAuthTypes {
+		authenticators = append(authenticators, a.authenticators[authType]...)
+	}
+	return authenticators
+}
+
+// GetEnvVars returns the env vars of all authenticators, the first one wins when several
+// authenticators set the same env var. REQUESTS_CA_BUNDLE points to the bundle of all OIDC CAs.
 func (a *Authentication) GetEnvVars() []corev1.EnvVar {
 	// Preallocate with estimated capacity (approximately 3 env vars per authenticator)
 	envVars := make([]corev1.EnvVar, 0, a.getTotalAuthenticatorCount()*3)
-	for _, typedAuthenticator := range a.authenticators {
-		for _, authenticator := range typedAuthenticator {
-			envVars = append(envVars, authenticator.GetEnvVars()...)
+	seen := make(map[string]bool)
+	for _, authenticator := range a.getAuthenticators() {
+		for _, envVar := range authenticator.GetEnvVars() {
+			if seen[envVar.Name] {
+				continue
+			}
+			seen[envVar.Name] = true
+			envVars = append(envVars, envVar)
 		}
 	}
+	if len(a.getOidcCAFiles()) > 0 {
+		envVars = append(envVars, corev1.EnvVar{Name: EnvKeyRequestsCABundle, Value: OidcCABundleFile})
+	}
 	return envVars
 }
 
+// getOidcCAFiles returns the distinct CA files of the OIDC providers verified with a secret class
+func (a *Authentication) getOidcCAFiles() []string {
+	files := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, authenticator := range a.authenticators[AuthenticatorTypeOIDC] {
+		oidc, ok := authenticator.(*oidcAuthenticator)
+		if !ok || oidc.getCASecretClass() == "" || seen[oidc.getCAFile()] {
+			continue
+		}
+		seen[oidc.getCAFile()] = true
+		files = append(files, oidc.getCAFile())
+	}
+	return files
+}
+
+// GetVolumes returns the volumes of all authenticators, authenticators sharing a secret class share the volume
 func (a *Authentication) GetVolumes() []corev1.Volume {
 	// Preallocate with estimated capacity (approximately 2 volumes per authenticator)
 	volumes := make([]corev1.Volume, 0, a.getTotalAuthenticatorCount()*2)
-	for _, typedAuthenticator := range a.authenticators {
-		for _, authenticator := range typedAuthenticator {
-			volumes = append(volumes, authenticator.GetVolumes()...)
+	seen := make(map[string]bool)
+	for _, authenticator := range a.getAuthenticators() {
+		for _, volume := range authenticator.GetVolumes() {
+			if seen[volume.Name] {
+				continue
+			}
+			seen[volume.Name] = true
+			volumes = append(volumes, volume)
 		}
 	}
 	return volumes
@@ -166,68 +254,181 @@ func (a *Authentication) GetVolumes() []corev1.Volume {
 func (a *Authentication) GetVolumeMounts() []corev1.VolumeMount {
 	// Preallocate with estimated capacity (approximately 2 volume mounts per authenticator)
 	mounts := make([]corev1.VolumeMount, 0, a.getTotalAuthenticatorCount()*2)
-	for _, typedAuthenticator := range a.authenticators {
-		for _, authenticator := range typedAuthenticator {
-			mounts = append(mounts, authenticator.GetVolumeMounts()...)
+	seen := make(map[string]bool)
+	for _, authenticator := range a.getAuthenticators() {
+		for _, mount := range authenticator.GetVolumeMounts() {
+			if seen[mount.MountPath] {
+				continue
+			}
+			seen[mount.MountPath] = true
+			mounts = append(mounts, mount)
 		}
 	}
 	return mounts
 }
 
+// GetCommands returns the shell commands the authenticators run before the webserver starts.
+// REQUESTS_CA_BUNDLE replaces the trust of requests process-wide, the bundle therefore combines the
+// certifi CAs requests trusts by default with the CAs of every OIDC provider, so webPki providers keep working.
+func (a *Authentication) GetCommands() []string {
+	commands := make([]string, 0)
+	for _, authenticator := range a.getAuthenticators() {
+		commands = append(commands, authenticator.GetCommands()...)
+	}
+	if caFiles := a.getOidcCAFiles(); len(caFiles) > 0 {
+		commands = append(commands, fmt.Sprintf(
+			`cat "$(python -c 'import certifi; print(certifi.where())')" %s > %s`,
+			strings.Join(caFiles, " "),
+			OidcCABundleFile,
+		))
+	}
+	return commands
+}
+
 func (a *Authentication) getAuthDBConfig() string {
-	return "AUTH_TYPE = 'AUTH_DB'"
+	return "AUTH_TYPE = AUTH_DB"
 }
 
-func (a *Authentication) getOidcConfig() (string, error) {
-	data := make(map[string]interface{})
-	for authType, typedAuthenticator := range a.authenticators {
-		providerData := make(map[string]string)
-		if authType == AuthenticatorTypeOIDC {
-			for _, authenticator := range typedAuthenticator {
-				cfg := authenticator.GetConfig()
-				for _, k := range cfg.Keys() {
-					v, _ := cfg.Get(k)
-					providerData[k] = v
-				}
-			}
-			data["providers"] = providerData
+// getRolesMapping renders the role mapping as python dict, the groups are sorted to keep the config stable
+func (a *Authentication) getRolesMapping() string {
+	if len(a.roleMapping) == 0 {
+		return ""
+	}
+	groups := slices.Sorted(maps.Keys(a.roleMapping))
+	entries := make([]string, 0, len(groups))
+	for _, group := range groups {
+		roles := make([]string, 0, len(a.roleMapping[group]))
+		for _, role := range a.roleMapping[group] {
+			roles = append(roles, strconv.Quote(role))
 		}
+		entries = append(entries, fmt.Sprintf("\t%s: [%s],", strconv.Quote(group), strings.Join(roles, ", ")))
+	}
+	return "{\n" + strings.Join(entries, "\n") + "\n}"
+}
+
+// isRolesSyncAtLogin reports whether roles are synced on every login. Roles taken from an OIDC
+// claim are synced at login unless syncRolesAt says otherwise.
+func (a *Authentication) isRolesSyncAtLogin() bool {
+	if a.syncRolesAt != nil && *a.syncRolesAt != "" {
+		return *a.syncRolesAt == "Login"
+	}
+	for _, authenticator := range a.authenticators[AuthenticatorTypeOIDC] {
+		if oidcAuth, ok := authenticator.(*oidcAuthenticator); ok && oidcAuth.rolesClaim != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getCommonConfig returns the settings shared by all authentication types
+func (a *Authentication) getCommonConfig(authType string) (string, error) {
+	data := map[string]interface{}{
+		"auth_type":                authType,
+		"auth_roles_sync_at_login": a.isRolesSyncAtLogin(),
+		"auth_roles_mapping":       a.getRolesMapping(),
+		"user_registration":        a.userRegistration != nil && *a.userRegistration,
+		"user_registration_role":   a.userRegistrationRole,
 	}
-	data["auth_type"] = "AUTH_OAUTH"
-	data["auth_roles_sync_at_login"] = a.syncRolesAt
-	data["user_registration"] = a.userRegistration
-	data["user_registration_role"] = a.userRegistrationRole
 
 	tpl := `
-AUTH_TYPE = '{{ .auth_type }}'
+AUTH_TYPE = {{ .auth_type }}
 
 {{- if .auth_roles_sync_at_login }}
-AUTH_ROLES_SYNC_AT_LOGIN = '{{ .auth_roles_sync_at_login }}'
+AUTH_ROLES_SYNC_AT_LOGIN = True
 {{- end }}
 {{- if .user_registration }}
-AUTH_USER_REGISTRATION = {{ .user_registration }}
+AUTH_USER_REGISTRATION = True
 {{- end }}
 {{- if .user_registration_role }}
 AUTH_USER_REGISTRATION_ROLE = '{{ .user_registration_role }}'
 {{- end }}
+{{- if .auth_roles_mapping }}
+AUTH_ROLES_MAPPING = {{ .auth_roles_mapping }}
+{{- end }}
+`
 
+	t := config.TemplateParser{Template: tpl, Value: data}
+	return t.Parse()
+}
+
+// getOidcConfig renders an OAUTH_PROVIDERS entry per OIDC authenticator, so users can pick the provider on the login page.
+// FAB only knows how to read the user info of well known provider names, so a security manager reading the
+// principal and roles claims of the providers is generated too.
+func (a *Authentication) getOidcConfig() (string, error) {
+	providers := make([]map[string]string, 0, len(a.authenticators[AuthenticatorTypeOIDC]))
+	for _, authenticator := range a.authenticators[AuthenticatorTypeOIDC] {
+		cfg := authenticator.GetConfig()
+		provider := make(map[string]string)
+		for _, k := range cfg.Keys() {
+			v, _ := cfg.Get(k)
+			provider[k] = v
+		}
+		providers = append(providers, provider)
+	}
+	data := map[string]interface{}{"providers": providers}
+
+	tpl := `
 OAUTH_PROVIDERS = [
 {{- range .providers }}
-					{
-						'name': 'keycloak',
-						'token_key': 'access_token',
-						'remote_app': {
-							'client_id': {{ .client_id }},
-							'client_secret': {{ .client_secret }},
-							'client_kwargs': {
-								'scope': {{ .scopes }},
-							},
-							'api_base_url': {{ .api_base_url }},
-							'server_metadata_url': {{ .server_metadata_url }},
-						}
-					},
-{{ end }}
-				]
+	{
+		'name': '{{ .name }}',
+		'icon': '{{ .icon }}',
+		'token_key': 'access_token',
+		'remote_app': {
+			'client_id': {{ .client_id }},
+			'client_secret': {{ .client_secret }},
+			'client_kwargs': {
+				'scope': '{{ .scopes }}',
+				{{- if .verify }}
+				'verify': {{ .verify }},
+				{{- end }}
+			},
+			'api_base_url': '{{ .api_base_url }}',
+			'server_metadata_url': '{{ .server_metadata_url }}',
+			'provider_hint': '{{ .provider_hint }}',
+		},
+	},
+{{- end }}
+]
+
+OIDC_PROVIDER_CLAIMS = {
+{{- range .providers }}
+	'{{ .name }}': {'principal': '{{ .principal_claim }}', 'roles': '{{ .roles_claim }}'},
+{{- end }}
+}
+
+try:
+	from airflow.providers.fab.auth_manager.security_manager.override import FabAirflowSecurityManagerOverride as BaseSecurityManager
+except ImportError:
+	from airflow.www.security import AirflowSecurityManager as BaseSecurityManager
+
+
+def get_claim(claims, name):
+	value = claims
+	for key in name.split('.'):
+		if not isinstance(value, dict):
+			return None
+		value = value.get(key)
+	return value
+
+
+class OidcSecurityManager(BaseSecurityManager):
+	def get_oauth_user_info(self, provider, resp):
+		claims = resp.get('userinfo') or self.oauth_remotes[provider].userinfo()
+		provider_claims = OIDC_PROVIDER_CLAIMS.get(provider, {})
+		user_info = {
+			'username': get_claim(claims, provider_claims.get('principal') or 'preferred_username'),
+			'email': claims.get('email'),
+			'first_name': claims.get('given_name', ''),
+			'last_name': claims.get('family_name', ''),
+		}
+		if provider_claims.get('roles'):
+			roles = get_claim(claims, provider_claims['roles']) or []
+			user_info['role_keys'] = [roles] if isinstance(roles, str) else list(roles)
+		return user_info
+
+
+SECURITY_MANAGER_CLASS = OidcSecurityManager
 `
 
 	t := config.TemplateParser{Template: tpl, Value: data}
@@ -235,42 +436,18 @@ OAUTH_PROVIDERS = [
 }
 
 func (a *Authentication) getLdapConfig() (string, error) {
+	authenticators := a.authenticators[AuthenticatorTypeLDAP]
+	if len(authenticators) > 1 {
+		authLogger.Info("Multiple LDAP authenticators found, using the first one")
+	}
 	data := make(map[string]interface{})
-	exist := false
-	for authType, typedAuthenticator := range a.authenticators {
-		if authType == AuthenticatorTypeLDAP {
-			if exist {
-				authLogger.Info("Multiple LDAP authenticators found, using the first one")
-				continue
-			}
-			for _, authenticator := range typedAuthenticator {
-				cfg := authenticator.GetConfig()
-				for _, k := range cfg.Keys() {
-					v, _ := cfg.Get(k)
-					data[k] = v
-				}
-			}
-			exist = true
-		}
+	cfg := authenticators[0].GetConfig()
+	for _, k := range cfg.Keys() {
+		v, _ := cfg.Get(k)
+		data[k] = v
 	}
-	data["auth_type"] = "AUTH_LDAP"
-	data["auth_roles_sync_at_login"] = a.syncRolesAt
-	data["user_registration"] = a.userRegistration
-	data["user_registration_role"] = a.userRegistrationRole
 
 	tpl := `
-AUTH_TYPE = '{{ .auth_type }}'
-
-{{- if .auth_roles_sync_at_login }}
-AUTH_ROLES_SYNC_AT_LOGIN = '{{ .auth_roles_sync_at_login }}'
-{{- end }}
-{{- if .user_registration }}
-AUTH_USER_REGISTRATION = {{ .user_registration }}
-{{- end }}
-{{- if .user_registration_role }}
-AUTH_USER_REGISTRATION_ROLE = '{{ .user_registration_role }}'
-{{- end }}
-
 AUTH_LDAP_SERVER = '{{ .auth_ldap_server }}'
 AUTH_LDAP_SEARCH = '{{ .auth_ldap_search }}'
 AUTH_LDAP_SEARCH_FILTER = '{{ .auth_ldap_search_filter }}'
@@ -280,6 +457,19 @@ AUTH_LDAP_FIRSTNAME_FIELD = '{{ .auth_ldap_firstname_field }}'
 AUTH_LDAP_LASTNAME_FIELD = '{{ .auth_ldap_lastname_field }}'
 AUTH_LDAP_EMAIL_FIELD = '{{ .auth_ldap_email_field }}'
 
+{{- if .auth_ldap_use_tls }}
+AUTH_LDAP_USE_TLS = {{ .auth_ldap_use_tls }}
+{{- end }}
+{{- if .auth_ldap_allow_self_signed }}
+AUTH_LDAP_ALLOW_SELF_SIGNED = {{ .auth_ldap_allow_self_signed }}
+{{- end }}
+{{- if .auth_ldap_tls_demand }}
+AUTH_LDAP_TLS_DEMAND = {{ .auth_ldap_tls_demand }}
+{{- end }}
+{{- if .auth_ldap_tls_cacertfile }}
+AUTH_LDAP_TLS_CACERTFILE = '{{ .auth_ldap_tls_cacertfile }}'
+{{- end }}
+
 {{- if .auth_ldap_bind_user_file }}
 with open('{{ .auth_ldap_bind_user_file }}', 'r') as f:
 	AUTH_LDAP_BIND_USER = f.read().strip()
@@ -295,31 +485,65 @@ with open('{{ .auth_ldap_bind_password_file }}', 'r') as f:
 	return t.Parse()
 }
 
+// GetConfig returns the webserver_config.py settings of the authenticators.
+// Flask AppBuilder supports a single AUTH_TYPE, so LDAP and OIDC can not be combined.
 func (a *Authentication) GetConfig() (string, error) {
-	if len(a.authenticators) == 0 {
+	hasLdap := len(a.authenticators[AuthenticatorTypeLDAP]) > 0
+	hasOidc := len(a.authenticators[AuthenticatorTypeOIDC]) > 0
+	if !hasLdap && !hasOidc {
 		return a.getAuthDBConfig(), nil
 	}
+	if hasLdap && hasOidc {
+		return "", fmt.Errorf("ldap and oidc authentication can not be used together")
+	}
 
-	configs := make([]string, 0)
+	authType := "AUTH_LDAP"
+	getConfig := a.getLdapConfig
+	if hasOidc {
+		authType = "AUTH_OAUTH"
+		getConfig = a.getOidcConfig
+	}
 
-	ldapConfig, err := a.getLdapConfig()
+	commonConfig, err := a.getCommonConfig(authType)
 	if err != nil {
-		authLogger.Error(err, "Failed to get LDAP config")
 		return "", err
 	}
-	configs = append(configs, ldapConfig)
-
-	oidcConfig, err := a.getOidcConfig()
+	authConfig, err := getConfig()
 	if err != nil {
-		authLogger.Error(err, "Failed to get OIDC config")
+		authLogger.Error(err, "Failed to get authentication config", "authType", authType)
 		return "", err
 	}
-	configs = append(configs, oidcConfig)
-	return strings.Join(configs, "\n"), nil
+	return strings.Join([]string{commonConfig, authConfig}, "\n"), nil
+}
+
+// getTLSVerification defaults a missing verification to no verification at all
+func getTLSVerification(verification *commonsv1alpha1.TLSVerificationSpec) *commonsv1alpha1.TLSVerificationSpec {
+	if verification == nil {
+		return &commonsv1alpha1.TLSVerificationSpec{}
+	}
+	return verification
+}
+
+// getCASecretClass returns the secret class providing the CA the server certificate is verified with,
+// it is empty when the server is not verified or verified against the system CAs (webPki).
+func getCASecretClass(verification *commonsv1alpha1.TLSVerificationSpec) string {
+	if verification == nil || verification.Server == nil || verification.Server.CACert == nil {
+		return ""
+	}
+	return verification.Server.CACert.SecretClass
+}
+
+// getCAVolume returns the secret-operator volume holding the PEM CA of the secret class
+func getCAVolume(name string, secretClass string) corev1.Volume {
+	b := builder.NewSecretOperatorVolume(name, secretClass)
+	b.SetFormatName(constants.TLSPEM)
+	return *b.Builde()
 }
 
 type ldapAuthenticator struct {
 	provider *authv1alpha1.LDAPProvider
+	// startTLS negotiates TLS on the plain LDAP port, otherwise a TLS server is reached with LDAPS
+	startTLS bool
 }
 
 func (a *ldapAuthenticator) GetEnvVars() []corev1.EnvVar {
@@ -327,9 +551,17 @@ func (a *ldapAuthenticator) GetEnvVars() []corev1.EnvVar {
 }
 
 func (a *ldapAuthenticator) GetVolumes() []corev1.Volume {
-	if a.provider.BindCredentials == nil {
-		return nil
+	volumes := make([]corev1.Volume, 0)
+	if a.provider.BindCredentials != nil {
+		volumes = append(volumes, a.getBindCredentialsVolume())
+	}
+	if secretClass := a.getCASecretClass(); secretClass != "" {
+		volumes = append(volumes, getCAVolume(a.getCAVolumeName(), secretClass))
 	}
+	return volumes
+}
+
+func (a *ldapAuthenticator) getBindCredentialsVolume() corev1.Volume {
 	secretClass := a.provider.BindCredentials.SecretClass
 
 	svcScope := make([]string, 0)
@@ -355,30 +587,72 @@ func (a *ldapAuthenticator) GetVolumes() []corev1.Volume {
 		Node:    nodeScope,
 		Service: svcScope,
 	})
-	return []corev1.Volume{*b.Builde()}
+	return *b.Builde()
 }
 
 func (a *ldapAuthenticator) getVolumeName() string {
-	return fmt.Sprintf("ldap-%s", a.provider.BindCredentials.SecretClass)
+	return LdapVolumeNamePrefix + a.provider.BindCredentials.SecretClass
 }
 
-func (a *ldapAuthenticator) GetVolumeMounts() []corev1.VolumeMount {
-	if a.provider.BindCredentials == nil {
+func (a *ldapAuthenticator) getCAVolumeName() string {
+	return LdapVolumeNamePrefix + "ca-" + a.getCASecretClass()
+}
+
+func (a *ldapAuthenticator) getCAMountPath() string {
+	return path.Join(constants.KubedoopSecretDir, "ldap-ca", a.getCASecretClass())
+}
+
+// getTLSVerification returns the verification of the server certificate, nil when TLS is off
+func (a *ldapAuthenticator) getTLSVerification() *commonsv1alpha1.TLSVerificationSpec {
+	if a.provider.TLS == nil {
 		return nil
 	}
-	return []corev1.VolumeMount{
-		{
+	return getTLSVerification(a.provider.TLS.Verification)
+}
+
+func (a *ldapAuthenticator) getCASecretClass() string {
+	return getCASecretClass(a.getTLSVerification())
+}
+
+// useStartTLS reports whether TLS is negotiated on the plain LDAP port with StartTLS,
+// otherwise a TLS server is reached with LDAPS.
+func (a *ldapAuthenticator) useStartTLS() bool {
+	return a.provider.TLS != nil && a.startTLS
+}
+
+// getPort returns the port of the server, LDAPS defaults to its own port
+func (a *ldapAuthenticator) getPort() int {
+	if a.provider.Port != 0 {
+		return a.provider.Port
+	}
+	if a.provider.TLS != nil && !a.useStartTLS() {
+		return DefaultLDAPSPort
+	}
+	return DefaultLDAPPort
+}
+
+func (a *ldapAuthenticator) GetVolumeMounts() []corev1.VolumeMount {
+	mounts := make([]corev1.VolumeMount, 0)
+	if a.provider.BindCredentials != nil {
+		mounts = append(mounts, corev1.VolumeMount{
 			Name:      a.getVolumeName(),
 			MountPath: path.Join(constants.KubedoopSecretDir, a.provider.BindCredentials.SecretClass),
-		},
+		})
+	}
+	if a.getCASecretClass() != "" {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      a.getCAVolumeName(),
+			MountPath: a.getCAMountPath(),
+		})
 	}
+	return mounts
 }
 
 func (a *ldapAuthenticator) GetConfig() *properties.Properties {
 
-	server := url.URL{Scheme: "ldap", Host: a.provider.Hostname}
-	if a.provider.Port != 0 {
-		server.Host += ":" + strconv.Itoa(a.provider.Port)
+	server := url.URL{Scheme: "ldap", Host: a.provider.Hostname + ":" + strconv.Itoa(a.getPort())}
+	if a.provider.TLS != nil && !a.useStartTLS() {
+		server.Scheme = "ldaps"
 	}
 
 	ldapFieldUid := DefaultLDAPFieldUid
@@ -405,13 +679,28 @@ func (a *ldapAuthenticator) GetConfig() *properties.Properties {
 	cfg.Add("auth_ldap_lastname_field", ldapFieldSurname)
 	cfg.Add("auth_ldap_email_field", ldapFieldEmail)
 
-	mountPath := path.Join(constants.KubedoopSecretDir, a.provider.BindCredentials.SecretClass)
-
 	if a.provider.BindCredentials != nil {
+		mountPath := path.Join(constants.KubedoopSecretDir, a.provider.BindCredentials.SecretClass)
 		cfg.Add("auth_ldap_bind_user_file", path.Join(mountPath, "username"))
 		cfg.Add("auth_ldap_bind_password_file", path.Join(mountPath, "password"))
 	}
 
+	if verification := a.getTLSVerification(); verification != nil {
+		if a.useStartTLS() {
+			cfg.Add("auth_ldap_use_tls", "True")
+		}
+		// without server verification any certificate is accepted
+		if verification.Server == nil {
+			cfg.Add("auth_ldap_allow_self_signed", "True")
+		} else {
+			cfg.Add("auth_ldap_allow_self_signed", "False")
+			cfg.Add("auth_ldap_tls_demand", "True")
+		}
+		if a.getCASecretClass() != "" {
+			cfg.Add("auth_ldap_tls_cacertfile", path.Join(a.getCAMountPath(), "ca.crt"))
+		}
+	}
+
 	return cfg
 }
 
@@ -420,14 +709,57 @@ func (a *ldapAuthenticator) GetCommands() []string {
 }
 
 type oidcAuthenticator struct {
-	config   *authv1alpha1.OidcSpec
-	provider *authv1alpha1.OIDCProvider
+	// name is the authentication class name, it tells the providers apart on the login page
+	name string
+	// rolesClaim is the claim mapped to Airflow roles, roles are not taken from the token when empty
+	rolesClaim string
+	config     *authv1alpha1.OidcSpec
+	provider   *authv1alpha1.OIDCProvider
+	// single is set when it is the only OIDC authenticator, it is then named DefaultOidcProviderName
+	single bool
+}
+
+// getProviderName returns the name of the provider on the login page and in its redirect URI
+func (a *oidcAuthenticator) getProviderName() string {
+	if a.single {
+		return DefaultOidcProviderName
+	}
+	return a.name
+}
+
+// getEnvKey returns the env var name of the provider, e.g. OIDC_CLIENT_ID_CORPORATE_SSO,
+// a single provider uses the prefix as is.
+func (a *oidcAuthenticator) getEnvKey(prefix string) string {
+	if a.single {
+		return prefix
+	}
+	suffix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, a.name)
+	return prefix + "_" + strings.ToUpper(suffix)
+}
+
+// getIcon returns the font awesome icon shown on the login button
+func (a *oidcAuthenticator) getIcon() string {
+	switch a.provider.ProviderHint {
+	case "google":
+		return "fa-google"
+	case "github":
+		return "fa-github"
+	case "keycloak":
+		return "fa-key"
+	default:
+		return "fa-address-card"
+	}
 }
 
 func (a *oidcAuthenticator) GetEnvVars() []corev1.EnvVar {
 	envVars := []corev1.EnvVar{
 		{
-			Name: EnvKeyOidcClientId,
+			Name: a.getEnvKey(EnvKeyOidcClientId),
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
 					Key: "CLIENT_ID",
@@ -438,7 +770,7 @@ func (a *oidcAuthenticator) GetEnvVars() []corev1.EnvVar {
 			},
 		},
 		{
-			Name: EnvKeyOidcClientSecret,
+			Name: a.getEnvKey(EnvKeyOidcClientSecret),
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
 					Key: "CLIENT_SECRET",
@@ -452,11 +784,41 @@ func (a *oidcAuthenticator) GetEnvVars() []corev1.EnvVar {
 	return envVars
 }
 
+// getTLSVerification returns the verification of the issuer certificate, nil when the issuer is served over http
+func (a *oidcAuthenticator) getTLSVerification() *commonsv1alpha1.TLSVerificationSpec {
+	if a.provider.TLS == nil {
+		return nil
+	}
+	return getTLSVerification(a.provider.TLS.Verification)
+}
+
+func (a *oidcAuthenticator) getCASecretClass() string {
+	return getCASecretClass(a.getTLSVerification())
+}
+
+func (a *oidcAuthenticator) getCAVolumeName() string {
+	return OidcCAVolumeNamePrefix + a.getCASecretClass()
+}
+
+func (a *oidcAuthenticator) getCAMountPath() string {
+	return path.Join(constants.KubedoopSecretDir, "oidc-ca", a.getCASecretClass())
+}
+
+func (a *oidcAuthenticator) getCAFile() string {
+	return path.Join(a.getCAMountPath(), "ca.crt")
+}
+
 func (a *oidcAuthenticator) GetVolumes() []corev1.Volume {
+	if secretClass := a.getCASecretClass(); secretClass != "" {
+		return []corev1.Volume{getCAVolume(a.getCAVolumeName(), secretClass)}
+	}
 	return nil
 }
 
 func (a *oidcAuthenticator) GetVolumeMounts() []corev1.VolumeMount {
+	if a.getCASecretClass() != "" {
+		return []corev1.VolumeMount{{Name: a.getCAVolumeName(), MountPath: a.getCAMountPath()}}
+	}
 	return nil
 }
 
@@ -470,20 +832,34 @@ func (a *oidcAuthenticator) GetConfig() *properties.Properties {
 		Host:   a.provider.Hostname,
 		Path:   a.provider.RootPath,
 	}
+	if a.provider.TLS != nil {
+		issuer.Scheme = "https"
+	}
 
 	if a.provider.Port != 0 {
 		issuer.Host = fmt.Sprintf("%s:%d", a.provider.Hostname, a.provider.Port)
 	}
 
-	// TODO: Add Tls support
-
 	cfg := properties.NewProperties()
-	cfg.Add("client_id", fmt.Sprintf("os.environ.get('%s')", EnvKeyOidcClientId))
-	cfg.Add("client_secret", fmt.Sprintf("os.environ.get('%s')", EnvKeyOidcClientSecret))
+	cfg.Add("name", a.getProviderName())
+	cfg.Add("icon", a.getIcon())
+	cfg.Add("client_id", fmt.Sprintf("os.environ.get('%s')", a.getEnvKey(EnvKeyOidcClientId)))
+	cfg.Add("client_secret", fmt.Sprintf("os.environ.get('%s')", a.getEnvKey(EnvKeyOidcClientSecret)))
 	cfg.Add("scopes", strings.Join(scopes, " "))
 	cfg.Add("api_base_url", fmt.Sprintf("%s/protocol/", issuer.String()))
 	cfg.Add("server_metadata_url", fmt.Sprintf("%s/.well-known/openid-configuration", issuer.String()))
 	cfg.Add("provider_hint", a.provider.ProviderHint)
+	cfg.Add("principal_claim", a.provider.PrincipalClaim)
+	cfg.Add("roles_claim", a.rolesClaim)
+
+	// verify is handed to the authlib requests session through client_kwargs, webPki keeps the system trust
+	if verification := a.getTLSVerification(); verification != nil {
+		if verification.Server == nil {
+			cfg.Add("verify", "False")
+		} else if a.getCASecretClass() != "" {
+			cfg.Add("verify", fmt.Sprintf("'%s'", a.getCAFile()))
+		}
+	}
 
 	return cfg
 }
@@ -0,0 +1,324 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commons
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("ldapAuthenticator", func() {
+	get := func(a *ldapAuthenticator, key string) string {
+		v, _ := a.GetConfig().Get(key)
+		return v
+	}
+
+	It("should use a plain ldap url without TLS", func() {
+		a := &ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{Hostname: "ldap.example.com", Port: DefaultLDAPPort}}
+		Expect(get(a, "auth_ldap_server")).To(Equal("ldap://ldap.example.com:389"))
+		Expect(get(a, "auth_ldap_use_tls")).To(BeEmpty())
+		Expect(get(a, "auth_ldap_allow_self_signed")).To(BeEmpty())
+		Expect(a.GetVolumes()).To(BeEmpty())
+		Expect(a.GetVolumeMounts()).To(BeEmpty())
+	})
+
+	It("should use ldaps and accept any certificate without server verification", func() {
+		a := &ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{
+			Hostname: "ldap.example.com",
+			Port:     DefaultLDAPSPort,
+			TLS: &authv1alpha1.LDAPTLS{
+				Verification: &commonsv1alpha1.TLSVerificationSpec{None: &commonsv1alpha1.NoneVerification{}},
+			},
+		}}
+		Expect(get(a, "auth_ldap_server")).To(Equal("ldaps://ldap.example.com:636"))
+		Expect(get(a, "auth_ldap_use_tls")).To(BeEmpty())
+		Expect(get(a, "auth_ldap_allow_self_signed")).To(Equal("True"))
+		Expect(get(a, "auth_ldap_tls_cacertfile")).To(BeEmpty())
+		Expect(a.GetVolumes()).To(BeEmpty())
+	})
+
+	It("should use StartTLS on the ldap port and mount the CA of the secret class", func() {
+		a := &ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{
+			Hostname: "ldap.example.com",
+			TLS: &authv1alpha1.LDAPTLS{
+				Verification: &commonsv1alpha1.TLSVerificationSpec{
+					Server: &commonsv1alpha1.ServerVerification{
+						CACert: &commonsv1alpha1.CACert{SecretClass: "tls"},
+					},
+				},
+			},
+		}, startTLS: true}
+		Expect(get(a, "auth_ldap_server")).To(Equal("ldap://ldap.example.com:389"))
+		Expect(get(a, "auth_ldap_use_tls")).To(Equal("True"))
+		Expect(get(a, "auth_ldap_allow_self_signed")).To(Equal("False"))
+		Expect(get(a, "auth_ldap_tls_demand")).To(Equal("True"))
+		Expect(get(a, "auth_ldap_tls_cacertfile")).To(Equal("/kubedoop/secret/ldap-ca/tls/ca.crt"))
+
+		volumes := a.GetVolumes()
+		Expect(volumes).To(HaveLen(1))
+		Expect(volumes[0].Name).To(Equal("ldap-ca-tls"))
+		Expect(volumes[0].Ephemeral).NotTo(BeNil())
+		Expect(a.GetVolumeMounts()).To(ConsistOf(HaveField("MountPath", "/kubedoop/secret/ldap-ca/tls")))
+	})
+
+	It("should pick the TLS mode independently of the port", func() {
+		tls := &authv1alpha1.LDAPTLS{Verification: &commonsv1alpha1.TLSVerificationSpec{None: &commonsv1alpha1.NoneVerification{}}}
+
+		a := &ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{Hostname: "ldap.example.com", Port: 1389, TLS: tls}, startTLS: true}
+		Expect(get(a, "auth_ldap_server")).To(Equal("ldap://ldap.example.com:1389"))
+		Expect(get(a, "auth_ldap_use_tls")).To(Equal("True"))
+
+		a = &ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{Hostname: "ldap.example.com", Port: DefaultLDAPPort, TLS: tls}}
+		Expect(get(a, "auth_ldap_server")).To(Equal("ldaps://ldap.example.com:389"))
+		Expect(get(a, "auth_ldap_use_tls")).To(BeEmpty())
+	})
+
+	It("should render the TLS settings into the webserver config", func() {
+		auth := &Authentication{authenticators: map[AuthenticatorType][]Authenticator{
+			AuthenticatorTypeLDAP: {&ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{
+				Hostname: "ldap.example.com",
+				TLS: &authv1alpha1.LDAPTLS{
+					Verification: &commonsv1alpha1.TLSVerificationSpec{
+						Server: &commonsv1alpha1.ServerVerification{CACert: &commonsv1alpha1.CACert{WebPki: &commonsv1alpha1.WebPki{}}},
+					},
+				},
+			}}},
+		}}
+		cfg, err := auth.getLdapConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(ContainSubstring("AUTH_LDAP_SERVER = 'ldaps://ldap.example.com:636'"))
+		Expect(cfg).To(ContainSubstring("AUTH_LDAP_TLS_DEMAND = True"))
+		Expect(cfg).NotTo(ContainSubstring("AUTH_LDAP_USE_TLS"))
+		Expect(cfg).NotTo(ContainSubstring("AUTH_LDAP_TLS_CACERTFILE"))
+	})
+})
+
+var _ = Describe("oidcAuthenticator", func() {
+	newAuthenticator := func(tls *authv1alpha1.OIDCTls) *oidcAuthenticator {
+		return &oidcAuthenticator{
+			name:   "keycloak",
+			config: &authv1alpha1.OidcSpec{ClientCredentialsSecret: "oidc-client"},
+			provider: &authv1alpha1.OIDCProvider{
+				Hostname:     "keycloak.example.com",
+				Port:         8443,
+				RootPath:     "/realms/kubedoop",
+				ProviderHint: "keycloak",
+				TLS:          tls,
+			},
+		}
+	}
+
+	It("should use a http issuer without TLS", func() {
+		a := newAuthenticator(nil)
+		v, _ := a.GetConfig().Get("server_metadata_url")
+		Expect(v).To(Equal("http://keycloak.example.com:8443/realms/kubedoop/.well-known/openid-configuration"))
+		_, ok := a.GetConfig().Get("verify")
+		Expect(ok).To(BeFalse())
+		Expect(a.GetVolumes()).To(BeEmpty())
+	})
+
+	It("should use a https issuer trusting the system CAs with webPki", func() {
+		a := newAuthenticator(&authv1alpha1.OIDCTls{Verification: &commonsv1alpha1.TLSVerificationSpec{
+			Server: &commonsv1alpha1.ServerVerification{CACert: &commonsv1alpha1.CACert{WebPki: &commonsv1alpha1.WebPki{}}},
+		}})
+		v, _ := a.GetConfig().Get("api_base_url")
+		Expect(v).To(Equal("https://keycloak.example.com:8443/realms/kubedoop/protocol/"))
+		_, ok := a.GetConfig().Get("verify")
+		Expect(ok).To(BeFalse())
+		Expect(a.GetEnvVars()).NotTo(ContainElement(HaveField("Name", EnvKeyRequestsCABundle)))
+	})
+
+	It("should not verify the issuer without server verification", func() {
+		a := newAuthenticator(&authv1alpha1.OIDCTls{})
+		v, _ := a.GetConfig().Get("verify")
+		Expect(v).To(Equal("False"))
+	})
+
+	It("should mount and trust the CA of the secret class", func() {
+		a := newAuthenticator(&authv1alpha1.OIDCTls{Verification: &commonsv1alpha1.TLSVerificationSpec{
+			Server: &commonsv1alpha1.ServerVerification{CACert: &commonsv1alpha1.CACert{SecretClass: "tls"}},
+		}})
+		v, _ := a.GetConfig().Get("verify")
+		Expect(v).To(Equal("'/kubedoop/secret/oidc-ca/tls/ca.crt'"))
+		Expect(a.GetVolumes()).To(ConsistOf(HaveField("Name", "oidc-ca-tls")))
+		Expect(a.GetVolumeMounts()).To(ConsistOf(HaveField("MountPath", "/kubedoop/secret/oidc-ca/tls")))
+
+		By("sharing the CA volume between providers of the same secret class")
+		auth := &Authentication{authenticators: map[AuthenticatorType][]Authenticator{
+			AuthenticatorTypeOIDC: {a, a},
+		}}
+		Expect(auth.GetVolumes()).To(HaveLen(1))
+		Expect(auth.GetVolumeMounts()).To(HaveLen(1))
+		Expect(auth.GetEnvVars()).To(HaveLen(3))
+		Expect(auth.GetEnvVars()).To(ContainElement(And(
+			HaveField("Name", EnvKeyRequestsCABundle),
+			HaveField("Value", OidcCABundleFile),
+		)))
+		Expect(auth.GetCommands()).To(ConsistOf(ContainSubstring(" /kubedoop/secret/oidc-ca/tls/ca.crt > " + OidcCABundleFile)))
+	})
+
+	It("should bundle the CAs of every provider with the system CAs", func() {
+		withCA := func(secretClass string) *oidcAuthenticator {
+			return newAuthenticator(&authv1alpha1.OIDCTls{Verification: &commonsv1alpha1.TLSVerificationSpec{
+				Server: &commonsv1alpha1.ServerVerification{CACert: &commonsv1alpha1.CACert{SecretClass: secretClass}},
+			}})
+		}
+		webPki := newAuthenticator(&authv1alpha1.OIDCTls{Verification: &commonsv1alpha1.TLSVerificationSpec{
+			Server: &commonsv1alpha1.ServerVerification{CACert: &commonsv1alpha1.CACert{WebPki: &commonsv1alpha1.WebPki{}}},
+		}})
+		auth := &Authentication{authenticators: map[AuthenticatorType][]Authenticator{
+			AuthenticatorTypeOIDC: {webPki, withCA("tls"), withCA("corporate-ca")},
+		}}
+
+		commands := auth.GetCommands()
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).To(ContainSubstring("certifi.where()"))
+		Expect(commands[0]).To(HaveSuffix(
+			" /kubedoop/secret/oidc-ca/tls/ca.crt /kubedoop/secret/oidc-ca/corporate-ca/ca.crt > " + OidcCABundleFile,
+		))
+		Expect(auth.GetEnvVars()).To(ContainElement(HaveField("Value", OidcCABundleFile)))
+		Expect(auth.GetVolumes()).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("Authentication", func() {
+	It("should render a named login entry per OIDC provider", func() {
+		auth := &Authentication{
+			authenticators: map[AuthenticatorType][]Authenticator{
+				AuthenticatorTypeOIDC: {
+					&oidcAuthenticator{
+						name:     "corporate-sso",
+						config:   &authv1alpha1.OidcSpec{ClientCredentialsSecret: "corporate-client"},
+						provider: &authv1alpha1.OIDCProvider{Hostname: "sso.example.com", ProviderHint: "keycloak", Scopes: []string{"openid"}},
+					},
+					&oidcAuthenticator{
+						name:     "partner",
+						config:   &authv1alpha1.OidcSpec{ClientCredentialsSecret: "partner-client", ExtraScopes: []string{"email"}},
+						provider: &authv1alpha1.OIDCProvider{Hostname: "idp.partner.com", ProviderHint: "google", Scopes: []string{"openid"}},
+					},
+				},
+			},
+			userRegistration: ptr.To(true),
+		}
+
+		cfg, err := auth.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(ContainSubstring("AUTH_TYPE = AUTH_OAUTH"))
+		Expect(cfg).To(ContainSubstring("AUTH_USER_REGISTRATION = True"))
+		Expect(cfg).To(ContainSubstring("'name': 'corporate-sso'"))
+		Expect(cfg).To(ContainSubstring("'icon': 'fa-key'"))
+		Expect(cfg).To(ContainSubstring("'client_id': os.environ.get('OIDC_CLIENT_ID_CORPORATE_SSO')"))
+		Expect(cfg).To(ContainSubstring("'name': 'partner'"))
+		Expect(cfg).To(ContainSubstring("'icon': 'fa-google'"))
+		Expect(cfg).To(ContainSubstring("'scope': 'openid email'"))
+		Expect(cfg).To(ContainSubstring("'provider_hint': 'google'"))
+		Expect(cfg).NotTo(ContainSubstring("AUTH_LDAP_SERVER"))
+
+		names := make([]string, 0)
+		for _, envVar := range auth.GetEnvVars() {
+			names = append(names, envVar.Name)
+		}
+		Expect(names).To(Equal([]string{
+			"OIDC_CLIENT_ID_CORPORATE_SSO", "OIDC_CLIENT_SECRET_CORPORATE_SSO",
+			"OIDC_CLIENT_ID_PARTNER", "OIDC_CLIENT_SECRET_PARTNER",
+		}))
+	})
+
+	It("should keep the provider name and env vars of a single OIDC provider", func() {
+		a := &oidcAuthenticator{
+			name:     "corporate-sso",
+			config:   &authv1alpha1.OidcSpec{ClientCredentialsSecret: "corporate-client"},
+			provider: &authv1alpha1.OIDCProvider{Hostname: "sso.example.com", Scopes: []string{"openid"}},
+			single:   true,
+		}
+		auth := &Authentication{authenticators: map[AuthenticatorType][]Authenticator{AuthenticatorTypeOIDC: {a}}}
+
+		cfg, err := auth.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(ContainSubstring("'name': 'keycloak'"))
+		Expect(cfg).To(ContainSubstring("'client_id': os.environ.get('OIDC_CLIENT_ID')"))
+		Expect(cfg).To(ContainSubstring("'keycloak': {'principal'"))
+		Expect(auth.GetEnvVars()).To(ConsistOf(HaveField("Name", "OIDC_CLIENT_ID"), HaveField("Name", "OIDC_CLIENT_SECRET")))
+	})
+
+	It("should render the LDAP group role mapping", func() {
+		auth := &Authentication{
+			authenticators: map[AuthenticatorType][]Authenticator{
+				AuthenticatorTypeLDAP: {&ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{Hostname: "ldap.example.com"}}},
+			},
+			syncRolesAt: ptr.To("Login"),
+			roleMapping: map[string][]string{
+				"cn=users,ou=groups,dc=example,dc=org":  {"User", "Viewer"},
+				"cn=admins,ou=groups,dc=example,dc=org": {"Admin"},
+			},
+		}
+
+		cfg, err := auth.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(ContainSubstring("AUTH_TYPE = AUTH_LDAP"))
+		Expect(cfg).To(ContainSubstring("AUTH_ROLES_SYNC_AT_LOGIN = True"))
+		Expect(cfg).To(ContainSubstring(`AUTH_ROLES_MAPPING = {
+	"cn=admins,ou=groups,dc=example,dc=org": ["Admin"],
+	"cn=users,ou=groups,dc=example,dc=org": ["User", "Viewer"],
+}`))
+	})
+
+	It("should generate a security manager mapping the OIDC roles claim", func() {
+		auth := &Authentication{
+			authenticators: map[AuthenticatorType][]Authenticator{
+				AuthenticatorTypeOIDC: {&oidcAuthenticator{
+					name:       "keycloak",
+					rolesClaim: "realm_access.roles",
+					config:     &authv1alpha1.OidcSpec{ClientCredentialsSecret: "oidc-client"},
+					provider:   &authv1alpha1.OIDCProvider{Hostname: "keycloak.example.com", PrincipalClaim: "preferred_username"},
+				}},
+			},
+			syncRolesAt: ptr.To(""),
+			roleMapping: map[string][]string{"airflow-admin": {"Admin"}},
+		}
+
+		cfg, err := auth.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(ContainSubstring("AUTH_ROLES_SYNC_AT_LOGIN = True"))
+		Expect(cfg).To(ContainSubstring(`"airflow-admin": ["Admin"],`))
+		Expect(cfg).To(ContainSubstring("'keycloak': {'principal': 'preferred_username', 'roles': 'realm_access.roles'},"))
+		Expect(cfg).To(ContainSubstring("class OidcSecurityManager(BaseSecurityManager):"))
+		Expect(cfg).To(ContainSubstring("SECURITY_MANAGER_CLASS = OidcSecurityManager"))
+
+		By("keeping the role sync explicitly set to registration")
+		auth.syncRolesAt = ptr.To("Registration")
+		cfg, err = auth.GetConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).NotTo(ContainSubstring("AUTH_ROLES_SYNC_AT_LOGIN"))
+	})
+
+	It("should not combine LDAP and OIDC", func() {
+		auth := &Authentication{authenticators: map[AuthenticatorType][]Authenticator{
+			AuthenticatorTypeLDAP: {&ldapAuthenticator{provider: &authv1alpha1.LDAPProvider{Hostname: "ldap.example.com"}}},
+			AuthenticatorTypeOIDC: {&oidcAuthenticator{
+				name:     "keycloak",
+				config:   &authv1alpha1.OidcSpec{ClientCredentialsSecret: "oidc-client"},
+				provider: &authv1alpha1.OIDCProvider{Hostname: "keycloak.example.com"},
+			}},
+		}}
+		_, err := auth.GetConfig()
+		Expect(err).To(HaveOccurred())
+	})
+})
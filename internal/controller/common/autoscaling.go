@@ -0,0 +1,521 @@
+package commons
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultCeleryQueue is the queue airflow sends tasks to when the operator sets none
+	DefaultCeleryQueue = "default"
+
+	DefaultCeleryTasksPerReplica = 16
+
+	// KedaPausedReplicasAnnotation makes KEDA hold the scale target at the given replicas
+	KedaPausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
+)
+
+var (
+	ScaledObjectGVK          = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledObject"}
+	TriggerAuthenticationGVK = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "TriggerAuthentication"}
+)
+
+// CeleryAutoscaler renders the KEDA trigger scaling the workers of a celery role group.
+// KEDA is not a dependency of the operator, its resources are built unstructured.
+type CeleryAutoscaler struct {
+	Spec             *airflowv1alpha1.CeleryAutoscalingSpec
+	Broker           *airflowv1alpha1.CeleryBrokerSpec
+	MetadataDatabase *MetadataDatabase
+	Queues           []string
+}
+
+func NewCeleryAutoscaler(
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	celery *airflowv1alpha1.CeleryExecutorsSpec,
+	spec *airflowv1alpha1.CeleryAutoscalingSpec,
+	queues []string,
+) *CeleryAutoscaler {
+	return &CeleryAutoscaler{
+		Spec:             spec,
+		Broker:           celery.Broker,
+		MetadataDatabase: NewMetadataDatabase(clusterConfig),
+		Queues:           queues,
+	}
+}
+
+func (a *CeleryAutoscaler) getTasksPerReplica() int32 {
+	if a.Spec.TasksPerReplica != nil {
+		return *a.Spec.TasksPerReplica
+	}
+	return DefaultCeleryTasksPerReplica
+}
+
+// GetMinReplicas returns the replicas the workers start with
+func (a *CeleryAutoscaler) GetMinReplicas() int32 {
+	if a.Spec.MinReplicas != nil {
+		return *a.Spec.MinReplicas
+	}
+	return 1
+}
+
+// secretTarget maps a KEDA authentication parameter to a key of a secret
+type secretTarget struct {
+	Parameter string
+	Name      string
+	Key       string
+}
+
+// getTriggers returns the KEDA trigger type, the metadata of each trigger and the credentials they authenticate with
+func (a *CeleryAutoscaler) getTriggers() (string, []map[string]string, []secretTarget, error) {
+	if a.Spec.Source == airflowv1alpha1.CeleryAutoscalingSourceBroker {
+		return a.getBrokerTriggers()
+	}
+	triggerType, metadata, targets, err := a.getDatabaseTrigger()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return triggerType, []map[string]string{metadata}, targets, nil
+}
+
+// getDatabaseTrigger counts the queued and running task instances of the queues,
+// one replica is wanted for every tasksPerReplica tasks.
+func (a *CeleryAutoscaler) getDatabaseTrigger() (string, map[string]string, []secretTarget, error) {
+	if a.MetadataDatabase == nil {
+		return "", nil, nil, fmt.Errorf("autoscaling on the database requires clusterConfig.metadataDatabase")
+	}
+	conn, err := a.MetadataDatabase.getConnection()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	queues := make([]string, 0, len(a.Queues))
+	for _, queue := range a.Queues {
+		queues = append(queues, "'"+strings.ReplaceAll(queue, "'", "''")+"'")
+	}
+	where := "state IN ('queued', 'running') AND queue IN (" + strings.Join(queues, ", ") + ")"
+	tasksPerReplica := strconv.Itoa(int(a.getTasksPerReplica()))
+
+	metadata := map[string]string{
+		"host":             conn.Host,
+		"dbName":           conn.Database,
+		"targetQueryValue": "1",
+	}
+	var triggerType string
+	if pg := a.MetadataDatabase.Spec.Postgresql; pg != nil {
+		triggerType = "postgresql"
+		metadata["port"] = strconv.Itoa(DefaultPostgresqlPort)
+		metadata["sslmode"] = getKedaPostgresqlSSLMode(pg)
+		metadata["query"] = "SELECT ceil(COUNT(*)::decimal / " + tasksPerReplica + ") FROM task_instance WHERE " + where
+	} else {
+		triggerType = "mysql"
+		metadata["port"] = strconv.Itoa(DefaultMysqlPort)
+		metadata["query"] = "SELECT CEIL(COUNT(*) / " + tasksPerReplica + ") FROM task_instance WHERE " + where
+	}
+	if conn.Port != 0 {
+		metadata["port"] = strconv.Itoa(int(conn.Port))
+	}
+
+	usernameParameter := "userName"
+	if triggerType == "mysql" {
+		usernameParameter = "username"
+	}
+	return triggerType, metadata, []secretTarget{
+		{Parameter: usernameParameter, Name: conn.CredentialsSecret, Key: DatabaseCredentialsUsernameKey},
+		{Parameter: "password", Name: conn.CredentialsSecret, Key: DatabaseCredentialsPasswordKey},
+	}, nil
+}
+
+// getKedaPostgresqlSSLMode returns the sslmode airflow connects with, libpq prefers TLS when none is set.
+// KEDA can not mount the CA of caSecretClass, it still connects over TLS without verifying the server then.
+func getKedaPostgresqlSSLMode(pg *airflowv1alpha1.PostgresqlSpec) string {
+	switch pg.SSLMode {
+	case "":
+		return "prefer"
+	case "verify-ca", "verify-full":
+		if pg.CASecretClass != "" {
+			return "require"
+		}
+	}
+	return pg.SSLMode
+}
+
+// getBrokerTriggers reads the length of every queue of the role group from the broker. A KEDA trigger
+// measures a single list, so each queue gets its own trigger and KEDA scales on the longest queue.
+func (a *CeleryAutoscaler) getBrokerTriggers() (string, []map[string]string, []secretTarget, error) {
+	if a.Broker == nil {
+		return "", nil, nil, fmt.Errorf("autoscaling on the broker requires celeryExecutors.broker")
+	}
+	triggers := make([]map[string]string, 0, len(a.Queues))
+	var triggerType string
+	var targets []secretTarget
+	for _, queue := range a.Queues {
+		var metadata map[string]string
+		var err error
+		triggerType, metadata, targets, err = a.getBrokerTrigger(queue)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		triggers = append(triggers, metadata)
+	}
+	return triggerType, triggers, targets, nil
+}
+
+// getBrokerTrigger reads the length of a queue from the broker
+func (a *CeleryAutoscaler) getBrokerTrigger(queue string) (string, map[string]string, []secretTarget, error) {
+	tasksPerReplica := strconv.Itoa(int(a.getTasksPerReplica()))
+
+	var triggerType string
+	var metadata map[string]string
+	var creds airflowv1alpha1.BrokerCredentialsSpec
+	switch {
+	case a.Broker.Redis != nil:
+		redis := a.Broker.Redis
+		port := redis.Port
+		if port == 0 {
+			port = DefaultRedisPort
+		}
+		triggerType, creds = "redis", redis.BrokerCredentialsSpec
+		metadata = map[string]string{
+			"address":       redis.Host + ":" + strconv.Itoa(int(port)),
+			"databaseIndex": strconv.Itoa(int(redis.DB)),
+			"listName":      queue,
+			"listLength":    tasksPerReplica,
+		}
+	case a.Broker.RedisSentinel != nil:
+		sentinel := a.Broker.RedisSentinel
+		triggerType, creds = "redis-sentinel", sentinel.BrokerCredentialsSpec
+		metadata = map[string]string{
+			"addresses":      strings.Join(sentinel.Hosts, ","),
+			"sentinelMaster": sentinel.MasterName,
+			"databaseIndex":  strconv.Itoa(int(sentinel.DB)),
+			"listName":       queue,
+			"listLength":     tasksPerReplica,
+		}
+	case a.Broker.Rabbitmq != nil:
+		rabbitmq := a.Broker.Rabbitmq
+		broker := &CeleryBroker{Broker: a.Broker}
+		uri, err := broker.GetBrokerURI()
+		if err != nil {
+			return "", nil, nil, err
+		}
+		triggerType, creds = "rabbitmq", rabbitmq.BrokerCredentialsSpec
+		metadata = map[string]string{
+			// the credentials are passed as authentication parameters
+			"host":      strings.Replace(uri.Template, credentialsPlaceholder(creds.CredentialsSecret), "", 1),
+			"protocol":  "amqp",
+			"queueName": queue,
+			"mode":      "QueueLength",
+			"value":     tasksPerReplica,
+		}
+	default:
+		return "", nil, nil, fmt.Errorf("celery broker has neither redis, redisSentinel nor rabbitmq set")
+	}
+	if creds.TLS != nil {
+		metadata["enableTLS"] = "true"
+		if triggerType == "rabbitmq" {
+			delete(metadata, "enableTLS")
+			metadata["host"] = strings.Replace(metadata["host"], "amqp://", "amqps://", 1)
+		}
+	}
+
+	var targets []secretTarget
+	if creds.CredentialsSecret != "" {
+		targets = append(targets, secretTarget{Parameter: "password", Name: creds.CredentialsSecret, Key: DatabaseCredentialsPasswordKey})
+		if triggerType == "rabbitmq" {
+			targets = append(targets, secretTarget{Parameter: "username", Name: creds.CredentialsSecret, Key: DatabaseCredentialsUsernameKey})
+		}
+	}
+	return triggerType, metadata, targets, nil
+}
+
+// NewCeleryAutoscalingReconcilers returns the reconcilers of the KEDA TriggerAuthentication and
+// ScaledObject of a role group. While the cluster is stopped the ScaledObject is paused at zero replicas.
+func NewCeleryAutoscalingReconcilers(
+	client *client.Client,
+	roleGroupInfo reconciler.RoleGroupInfo,
+	autoscaler *CeleryAutoscaler,
+	stopped bool,
+	options ...builder.Option,
+) []reconciler.Reconciler {
+	name := roleGroupInfo.GetFullName()
+	return []reconciler.Reconciler{
+		NewUnstructuredReconciler(
+			client,
+			&TriggerAuthenticationBuilder{ObjectMeta: *builder.NewObjectMeta(client, name, options...), Autoscaler: autoscaler},
+		),
+		NewUnstructuredReconciler(
+			client,
+			&ScaledObjectBuilder{ObjectMeta: *builder.NewObjectMeta(client, name, options...), Autoscaler: autoscaler, Stopped: stopped},
+			KedaPausedReplicasAnnotation,
+		),
+	}
+}
+
+// NewCeleryAutoscalingCleanupReconcilers returns the reconcilers deleting the KEDA ScaledObject and
+// TriggerAuthentication of a role group without autoscaling, so KEDA no longer scales its StatefulSet.
+func NewCeleryAutoscalingCleanupReconcilers(client *client.Client, roleGroupInfo reconciler.RoleGroupInfo) []reconciler.Reconciler {
+	name := roleGroupInfo.GetFullName()
+	return []reconciler.Reconciler{
+		NewUnstructuredDeleter(client, ScaledObjectGVK, name),
+		NewUnstructuredDeleter(client, TriggerAuthenticationGVK, name),
+	}
+}
+
+var _ reconciler.Reconciler = &UnstructuredDeleter{}
+
+// UnstructuredDeleter deletes an unstructured resource by name, a missing resource
+// or a cluster without its CRD is nothing to delete.
+type UnstructuredDeleter struct {
+	Client *client.Client
+	GVK    schema.GroupVersionKind
+	Name   string
+}
+
+func NewUnstructuredDeleter(client *client.Client, gvk schema.GroupVersionKind, name string) *UnstructuredDeleter {
+	return &UnstructuredDeleter{
+		Client: client,
+		GVK:    gvk,
+		Name:   name,
+	}
+}
+
+func (r *UnstructuredDeleter) GetName() string {
+	return r.Name
+}
+
+func (r *UnstructuredDeleter) GetNamespace() string {
+	return r.Client.GetOwnerNamespace()
+}
+
+func (r *UnstructuredDeleter) GetClient() *client.Client {
+	return r.Client
+}
+
+func (r *UnstructuredDeleter) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	obj := newUnstructured(r.GVK, r.GetNamespace(), r.Name, nil, nil)
+	if err := r.Client.GetCtrlClient().Delete(ctx, obj); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *UnstructuredDeleter) Ready(_ context.Context) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
+
+var _ reconciler.ResourceReconciler[builder.ObjectBuilder] = &UnstructuredReconciler{}
+
+// UnstructuredReconciler creates or updates the unstructured KEDA resources. The CreateOrUpdate of
+// operator-go only updates typed objects, the spec, labels and annotations of an existing resource
+// are updated in place instead, keeping the finalizers, labels and status KEDA sets.
+type UnstructuredReconciler struct {
+	reconciler.GenericResourceReconciler[builder.ObjectBuilder]
+
+	// ManagedAnnotations are removed from the existing resource when the builder no longer sets them
+	ManagedAnnotations []string
+}
+
+func NewUnstructuredReconciler(
+	client *client.Client,
+	builder builder.ObjectBuilder,
+	managedAnnotations ...string,
+) *UnstructuredReconciler {
+	return &UnstructuredReconciler{
+		GenericResourceReconciler: *reconciler.NewGenericResourceReconciler(client, builder),
+		ManagedAnnotations:        managedAnnotations,
+	}
+}
+
+func (r *UnstructuredReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	resource, err := r.GetBuilder().Build(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.ResourceReconcile(ctx, resource)
+}
+
+func (r *UnstructuredReconciler) ResourceReconcile(ctx context.Context, resource ctrlclient.Object) (ctrl.Result, error) {
+	desired, ok := resource.(*unstructured.Unstructured)
+	if !ok {
+		return ctrl.Result{}, fmt.Errorf("resource %s is %T, not unstructured", resource.GetName(), resource)
+	}
+	gvk := desired.GroupVersionKind()
+	if err := r.Client.SetOwnerReference(desired, &gvk); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(gvk)
+	if err := r.Client.GetCtrlClient().Get(ctx, ctrlclient.ObjectKeyFromObject(desired), current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err := r.Client.GetCtrlClient().Create(ctx, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+	}
+
+	updated := current.DeepCopy()
+	updated.Object["spec"] = desired.Object["spec"]
+	updated.SetOwnerReferences(desired.GetOwnerReferences())
+
+	updated.SetLabels(mergeStringMap(updated.GetLabels(), desired.GetLabels()))
+	updated.SetAnnotations(mergeStringMap(updated.GetAnnotations(), desired.GetAnnotations(), r.ManagedAnnotations...))
+
+	if equality.Semantic.DeepEqual(current.Object, updated.Object) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.Client.GetCtrlClient().Update(ctx, updated); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.RequeueAfter}, nil
+}
+
+var _ builder.ObjectBuilder = &TriggerAuthenticationBuilder{}
+
+// TriggerAuthenticationBuilder renders the KEDA TriggerAuthentication handing the credentials
+// of the database or broker to the trigger, it is named after the role group.
+type TriggerAuthenticationBuilder struct {
+	builder.ObjectMeta
+	Autoscaler *CeleryAutoscaler
+}
+
+func (b *TriggerAuthenticationBuilder) Build(_ context.Context) (ctrlclient.Object, error) {
+	_, _, targets, err := b.Autoscaler.getTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	secretTargetRef := make([]interface{}, 0, len(targets))
+	for _, target := range targets {
+		secretTargetRef = append(secretTargetRef, map[string]interface{}{
+			"parameter": target.Parameter,
+			"name":      target.Name,
+			"key":       target.Key,
+		})
+	}
+
+	obj := newUnstructured(TriggerAuthenticationGVK, b.GetObjectMeta().Namespace, b.GetName(), b.GetLabels(), b.GetAnnotations())
+	obj.Object["spec"] = map[string]interface{}{"secretTargetRef": secretTargetRef}
+	return obj, nil
+}
+
+var _ builder.ObjectBuilder = &ScaledObjectBuilder{}
+
+// ScaledObjectBuilder renders the KEDA ScaledObject scaling the StatefulSet of the role group
+type ScaledObjectBuilder struct {
+	builder.ObjectMeta
+	Autoscaler *CeleryAutoscaler
+	Stopped    bool
+}
+
+func (b *ScaledObjectBuilder) Build(_ context.Context) (ctrlclient.Object, error) {
+	triggerType, triggersMetadata, _, err := b.Autoscaler.getTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	triggers := make([]interface{}, 0, len(triggersMetadata))
+	for _, metadata := range triggersMetadata {
+		triggerMetadata := make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			triggerMetadata[k] = v
+		}
+		triggers = append(triggers, map[string]interface{}{
+			"type":              triggerType,
+			"metadata":          triggerMetadata,
+			"authenticationRef": map[string]interface{}{"name": b.GetName()},
+		})
+	}
+
+	spec := map[string]interface{}{
+		"scaleTargetRef": map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "StatefulSet",
+			"name":       b.GetName(),
+		},
+		"minReplicaCount": int64(b.Autoscaler.GetMinReplicas()),
+		"maxReplicaCount": int64(b.Autoscaler.Spec.MaxReplicas),
+		"triggers":        triggers,
+	}
+	if b.Autoscaler.Spec.PollingInterval != nil {
+		spec["pollingInterval"] = int64(*b.Autoscaler.Spec.PollingInterval)
+	}
+	if b.Autoscaler.Spec.CooldownPeriod != nil {
+		spec["cooldownPeriod"] = int64(*b.Autoscaler.Spec.CooldownPeriod)
+	}
+
+	annotations := b.GetAnnotations()
+	if b.Stopped {
+		annotations = make(map[string]string, len(b.GetAnnotations())+1)
+		for k, v := range b.GetAnnotations() {
+			annotations[k] = v
+		}
+		annotations[KedaPausedReplicasAnnotation] = "0"
+	}
+
+	obj := newUnstructured(ScaledObjectGVK, b.GetObjectMeta().Namespace, b.GetName(), b.GetLabels(), annotations)
+	obj.Object["spec"] = spec
+	return obj, nil
+}
+
+// mergeStringMap sets the desired entries on the current map after removing the removed keys,
+// an empty result is nil so unchanged metadata compares equal.
+func mergeStringMap(current, desired map[string]string, removed ...string) map[string]string {
+	merged := make(map[string]string, len(current)+len(desired))
+	maps.Copy(merged, current)
+	for _, key := range removed {
+		delete(merged, key)
+	}
+	maps.Copy(merged, desired)
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func newUnstructured(gvk schema.GroupVersionKind, namespace, name string, labels, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+// getAutoscaledReplicas keeps the replicas KEDA set on the StatefulSet,
+// a new StatefulSet starts with the minimum replicas.
+func (b *StatefulSetBuilder) getAutoscaledReplicas(ctx context.Context) (*int32, error) {
+	current := &appsv1.StatefulSet{}
+	key := ctrlclient.ObjectKey{Namespace: b.Client.GetOwnerNamespace(), Name: b.Name}
+	if err := b.Client.GetCtrlClient().Get(ctx, key, current); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ptr.To(b.Autoscaler.GetMinReplicas()), nil
+		}
+		return nil, err
+	}
+	return current.Spec.Replicas, nil
+}
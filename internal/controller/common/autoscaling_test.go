@@ -0,0 +1,327 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commons
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+var _ = Describe("CeleryAutoscaler", func() {
+	var (
+		ctx           = context.Background()
+		objects       []ctrlclient.Object
+		c             *client.Client
+		clusterConfig *airflowv1alpha1.ClusterConfigSpec
+		celery        *airflowv1alpha1.CeleryExecutorsSpec
+		spec          *airflowv1alpha1.CeleryAutoscalingSpec
+		queues        []string
+	)
+
+	name := "airflow-celeryexecutors-default"
+
+	BeforeEach(func() {
+		objects = nil
+		queues = []string{DefaultCeleryQueue}
+		clusterConfig = &airflowv1alpha1.ClusterConfigSpec{
+			Credentials: "airflow-credentials",
+			MetadataDatabase: &airflowv1alpha1.MetadataDatabaseSpec{
+				Postgresql: &airflowv1alpha1.PostgresqlSpec{
+					DatabaseConnectionSpec: airflowv1alpha1.DatabaseConnectionSpec{
+						Host:              "postgresql",
+						Database:          "airflow",
+						CredentialsSecret: "airflow-db",
+					},
+				},
+			},
+		}
+		celery = &airflowv1alpha1.CeleryExecutorsSpec{
+			Broker: &airflowv1alpha1.CeleryBrokerSpec{
+				Redis: &airflowv1alpha1.RedisSpec{
+					Host:                  "redis",
+					BrokerCredentialsSpec: airflowv1alpha1.BrokerCredentialsSpec{CredentialsSecret: "redis"},
+				},
+			},
+		}
+		spec = &airflowv1alpha1.CeleryAutoscalingSpec{
+			MinReplicas: ptr.To[int32](2),
+			MaxReplicas: 10,
+			Source:      airflowv1alpha1.CeleryAutoscalingSourceDatabase,
+		}
+	})
+
+	JustBeforeEach(func() {
+		cluster := &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+		}
+		c = client.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(), cluster)
+	})
+
+	buildScaledObject := func(stopped bool) *unstructured.Unstructured {
+		b := &ScaledObjectBuilder{
+			ObjectMeta: *builder.NewObjectMeta(c, name),
+			Autoscaler: NewCeleryAutoscaler(clusterConfig, celery, spec, queues),
+			Stopped:    stopped,
+		}
+		obj, err := b.Build(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		return obj.(*unstructured.Unstructured)
+	}
+
+	buildTriggerAuthentication := func() *unstructured.Unstructured {
+		b := &TriggerAuthenticationBuilder{
+			ObjectMeta: *builder.NewObjectMeta(c, name),
+			Autoscaler: NewCeleryAutoscaler(clusterConfig, celery, spec, queues),
+		}
+		obj, err := b.Build(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		return obj.(*unstructured.Unstructured)
+	}
+
+	trigger := func(obj *unstructured.Unstructured) map[string]interface{} {
+		triggers, _, err := unstructured.NestedSlice(obj.Object, "spec", "triggers")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(triggers).To(HaveLen(1))
+		return triggers[0].(map[string]interface{})
+	}
+
+	It("scales the statefulset of the role group on queued task instances", func() {
+		obj := buildScaledObject(false)
+
+		Expect(obj.GroupVersionKind()).To(Equal(ScaledObjectGVK))
+		Expect(obj.GetNamespace()).To(Equal("default"))
+		Expect(obj.GetAnnotations()).NotTo(HaveKey(KedaPausedReplicasAnnotation))
+
+		target, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "scaleTargetRef")
+		Expect(target).To(HaveKeyWithValue("kind", "StatefulSet"))
+		Expect(target).To(HaveKeyWithValue("name", name))
+
+		minReplicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "minReplicaCount")
+		maxReplicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "maxReplicaCount")
+		Expect(minReplicas).To(Equal(int64(2)))
+		Expect(maxReplicas).To(Equal(int64(10)))
+
+		t := trigger(obj)
+		Expect(t).To(HaveKeyWithValue("type", "postgresql"))
+		Expect(t["authenticationRef"]).To(HaveKeyWithValue("name", name))
+		metadata := t["metadata"].(map[string]interface{})
+		Expect(metadata).To(HaveKeyWithValue("host", "postgresql"))
+		Expect(metadata).To(HaveKeyWithValue("port", "5432"))
+		Expect(metadata["query"]).To(ContainSubstring("/ 16) FROM task_instance"))
+		Expect(metadata["query"]).To(ContainSubstring("queue IN ('default')"))
+	})
+
+	It("connects with the sslmode of the database", func() {
+		metadata := trigger(buildScaledObject(false))["metadata"].(map[string]interface{})
+		Expect(metadata).To(HaveKeyWithValue("sslmode", "prefer"))
+
+		clusterConfig.MetadataDatabase.Postgresql.SSLMode = "require"
+		metadata = trigger(buildScaledObject(false))["metadata"].(map[string]interface{})
+		Expect(metadata).To(HaveKeyWithValue("sslmode", "require"))
+
+		clusterConfig.MetadataDatabase.Postgresql.SSLMode = "verify-full"
+		clusterConfig.MetadataDatabase.Postgresql.CASecretClass = "tls"
+		metadata = trigger(buildScaledObject(false))["metadata"].(map[string]interface{})
+		Expect(metadata).To(HaveKeyWithValue("sslmode", "require"))
+	})
+
+	It("counts the task instances of the queues of the role group", func() {
+		queues = []string{"gpu", "heavy-etl"}
+		metadata := trigger(buildScaledObject(false))["metadata"].(map[string]interface{})
+		Expect(metadata["query"]).To(ContainSubstring("queue IN ('gpu', 'heavy-etl')"))
+	})
+
+	It("reads the database credentials from the secret", func() {
+		obj := buildTriggerAuthentication()
+
+		Expect(obj.GroupVersionKind()).To(Equal(TriggerAuthenticationGVK))
+		refs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "secretTargetRef")
+		Expect(refs).To(ConsistOf(
+			map[string]interface{}{"parameter": "userName", "name": "airflow-db", "key": "username"},
+			map[string]interface{}{"parameter": "password", "name": "airflow-db", "key": "password"},
+		))
+	})
+
+	It("pauses the workers at zero replicas while stopped", func() {
+		obj := buildScaledObject(true)
+		Expect(obj.GetAnnotations()).To(HaveKeyWithValue(KedaPausedReplicasAnnotation, "0"))
+	})
+
+	Context("on the broker", func() {
+		BeforeEach(func() {
+			spec.Source = airflowv1alpha1.CeleryAutoscalingSourceBroker
+			spec.TasksPerReplica = ptr.To[int32](4)
+		})
+
+		It("scales on the length of the redis list", func() {
+			t := trigger(buildScaledObject(false))
+			Expect(t).To(HaveKeyWithValue("type", "redis"))
+			metadata := t["metadata"].(map[string]interface{})
+			Expect(metadata).To(HaveKeyWithValue("address", "redis:6379"))
+			Expect(metadata).To(HaveKeyWithValue("listName", "default"))
+			Expect(metadata).To(HaveKeyWithValue("listLength", "4"))
+
+			refs, _, _ := unstructured.NestedSlice(buildTriggerAuthentication().Object, "spec", "secretTargetRef")
+			Expect(refs).To(ConsistOf(map[string]interface{}{"parameter": "password", "name": "redis", "key": "password"}))
+		})
+
+		It("scales on the length of every queue of the role group", func() {
+			queues = []string{"gpu", "heavy-etl"}
+			triggers, _, err := unstructured.NestedSlice(buildScaledObject(false).Object, "spec", "triggers")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggers).To(HaveLen(2))
+			for i, queue := range queues {
+				t := triggers[i].(map[string]interface{})
+				Expect(t).To(HaveKeyWithValue("type", "redis"))
+				Expect(t["metadata"]).To(HaveKeyWithValue("listName", queue))
+				Expect(t["authenticationRef"]).To(HaveKeyWithValue("name", name))
+			}
+		})
+
+		It("passes rabbitmq credentials as parameters", func() {
+			celery.Broker = &airflowv1alpha1.CeleryBrokerSpec{
+				Rabbitmq: &airflowv1alpha1.RabbitmqSpec{
+					Host:                  "rabbitmq",
+					BrokerCredentialsSpec: airflowv1alpha1.BrokerCredentialsSpec{CredentialsSecret: "rabbitmq"},
+				},
+			}
+			t := trigger(buildScaledObject(false))
+			Expect(t).To(HaveKeyWithValue("type", "rabbitmq"))
+			Expect(t["metadata"]).To(HaveKeyWithValue("host", "amqp://rabbitmq:5672/%2F"))
+		})
+	})
+
+	Context("reconciled", func() {
+		reconcile := func(stopped bool) ctrl.Result {
+			r := NewUnstructuredReconciler(
+				c,
+				&ScaledObjectBuilder{
+					ObjectMeta: *builder.NewObjectMeta(c, name),
+					Autoscaler: NewCeleryAutoscaler(clusterConfig, celery, spec, queues),
+					Stopped:    stopped,
+				},
+				KedaPausedReplicasAnnotation,
+			)
+			result, err := r.Reconcile(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		get := func() *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(ScaledObjectGVK)
+			Expect(c.GetCtrlClient().Get(ctx, ctrlclient.ObjectKey{Namespace: "default", Name: name}, obj)).To(Succeed())
+			return obj
+		}
+
+		It("updates the existing scaled object", func() {
+			Expect(reconcile(false).RequeueAfter).NotTo(BeZero())
+
+			// KEDA adds a finalizer to the scaled objects it handles
+			obj := get()
+			obj.SetFinalizers([]string{"finalizer.keda.sh"})
+			Expect(c.GetCtrlClient().Update(ctx, obj)).To(Succeed())
+
+			spec.MaxReplicas = 20
+			Expect(reconcile(true).RequeueAfter).NotTo(BeZero())
+			obj = get()
+			maxReplicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "maxReplicaCount")
+			Expect(maxReplicas).To(Equal(int64(20)))
+			Expect(obj.GetAnnotations()).To(HaveKeyWithValue(KedaPausedReplicasAnnotation, "0"))
+			Expect(obj.GetFinalizers()).To(ConsistOf("finalizer.keda.sh"))
+
+			Expect(reconcile(false).RequeueAfter).NotTo(BeZero())
+			Expect(get().GetAnnotations()).NotTo(HaveKey(KedaPausedReplicasAnnotation))
+
+			Expect(reconcile(false).RequeueAfter).To(BeZero())
+		})
+
+		It("deletes the scaled object once autoscaling is removed", func() {
+			reconcile(false)
+
+			deleter := NewUnstructuredDeleter(c, ScaledObjectGVK, name)
+			for range 2 {
+				_, err := deleter.Reconcile(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(ScaledObjectGVK)
+			err := c.GetCtrlClient().Get(ctx, ctrlclient.ObjectKey{Namespace: "default", Name: name}, obj)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("the statefulset", func() {
+		build := func() *appsv1.StatefulSet {
+			b := NewStatefulSetBuilder(
+				c,
+				name,
+				clusterConfig,
+				ptr.To[int32](1),
+				&util.Image{Repo: "quay.io/zncdatadev", ProductName: "airflow", ProductVersion: "2.10.2", KubedoopVersion: "0.0.0-dev"},
+				nil,
+				nil,
+				nil,
+				CeleryExecutor,
+				NewCeleryBroker(clusterConfig, celery),
+				nil,
+				func(o *builder.Options) {
+					o.ClusterName = "airflow"
+					o.RoleName = string(airflowv1alpha1.CeleryExecutorsRoleName)
+					o.RoleGroupName = "default"
+				},
+			)
+			b.Autoscaler = NewCeleryAutoscaler(clusterConfig, celery, spec, queues)
+			obj, err := b.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			return obj.(*appsv1.StatefulSet)
+		}
+
+		It("starts with the minimum replicas", func() {
+			Expect(build().Spec.Replicas).To(Equal(ptr.To[int32](2)))
+		})
+
+		Context("scaled by KEDA", func() {
+			BeforeEach(func() {
+				objects = append(objects, &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](7)},
+				})
+			})
+
+			It("keeps the replicas", func() {
+				Expect(build().Spec.Replicas).To(Equal(ptr.To[int32](7)))
+			})
+		})
+	})
+})
@@ -0,0 +1,344 @@
+package commons
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	DefaultRedisPort       = 6379
+	DefaultRabbitmqPort    = 5672
+	DefaultRabbitmqTLSPort = 5671
+	DefaultRabbitmqVhost   = "/"
+
+	CeleryConfigFileName = "celery_config.py"
+
+	CeleryBrokerEnvPrefix        = "CELERY_BROKER"
+	CeleryResultBackendEnvPrefix = "CELERY_RESULT_BACKEND"
+
+	CeleryBrokerVolumeName        = "celery-broker-ca"
+	CeleryResultBackendVolumeName = "celery-result-backend-ca"
+)
+
+var (
+	CeleryBrokerCAPath        = path.Join(constants.KubedoopSecretDir, "celery-broker")
+	CeleryResultBackendCAPath = path.Join(constants.KubedoopSecretDir, "celery-result-backend")
+)
+
+// CeleryBroker renders the celery settings of CeleryExecutorsSpec.Broker and
+// CeleryExecutorsSpec.ResultBackend. Urls are set with AIRFLOW__CELERY__* env vars,
+// settings airflow has no option for, like TLS and sentinel master, are set in
+// celery_config.py which airflow loads with celery_config_options.
+type CeleryBroker struct {
+	Broker           *airflowv1alpha1.CeleryBrokerSpec
+	ResultBackend    *airflowv1alpha1.CeleryResultBackendSpec
+	MetadataDatabase *MetadataDatabase
+}
+
+// NewCeleryBroker returns nil when no broker is configured, the broker url and
+// result backend are then read from the credentials secret.
+func NewCeleryBroker(clusterConfig *airflowv1alpha1.ClusterConfigSpec, spec *airflowv1alpha1.CeleryExecutorsSpec) *CeleryBroker {
+	if spec == nil || spec.Broker == nil {
+		return nil
+	}
+	return &CeleryBroker{
+		Broker:           spec.Broker,
+		ResultBackend:    spec.ResultBackend,
+		MetadataDatabase: NewMetadataDatabase(clusterConfig),
+	}
+}
+
+func (c *CeleryBroker) getBrokerCredentials() (*airflowv1alpha1.BrokerCredentialsSpec, error) {
+	switch {
+	case c.Broker.Redis != nil:
+		return &c.Broker.Redis.BrokerCredentialsSpec, nil
+	case c.Broker.RedisSentinel != nil:
+		return &c.Broker.RedisSentinel.BrokerCredentialsSpec, nil
+	case c.Broker.Rabbitmq != nil:
+		return &c.Broker.Rabbitmq.BrokerCredentialsSpec, nil
+	default:
+		return nil, fmt.Errorf("celery broker has neither redis, redisSentinel nor rabbitmq set")
+	}
+}
+
+func (c *CeleryBroker) getResultBackendRedis() *airflowv1alpha1.RedisSpec {
+	if c.ResultBackend == nil {
+		return nil
+	}
+	return c.ResultBackend.Redis
+}
+
+// GetBrokerURI returns the broker url, sentinel urls are joined with semicolons as kombu expects
+func (c *CeleryBroker) GetBrokerURI() (*ConnectionURI, error) {
+	creds, err := c.getBrokerCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	var uri string
+	usernameOptional := true
+	switch {
+	case c.Broker.Redis != nil:
+		uri = getRedisURI(c.Broker.Redis)
+	case c.Broker.RedisSentinel != nil:
+		sentinel := c.Broker.RedisSentinel
+		uris := make([]string, 0, len(sentinel.Hosts))
+		for _, host := range sentinel.Hosts {
+			uris = append(uris, "sentinel://"+credentialsPlaceholder(creds.CredentialsSecret)+host+"/"+strconv.Itoa(int(sentinel.DB)))
+		}
+		uri = strings.Join(uris, ";")
+	case c.Broker.Rabbitmq != nil:
+		rabbitmq := c.Broker.Rabbitmq
+		port := rabbitmq.Port
+		if port == 0 {
+			port = DefaultRabbitmqPort
+			if rabbitmq.TLS != nil {
+				port = DefaultRabbitmqTLSPort
+			}
+		}
+		vhost := rabbitmq.Vhost
+		if vhost == "" {
+			vhost = DefaultRabbitmqVhost
+		}
+		uri = "amqp://" + credentialsPlaceholder(creds.CredentialsSecret) + rabbitmq.Host + ":" + strconv.Itoa(int(port)) + "/" + url.PathEscape(vhost)
+		usernameOptional = false
+	}
+
+	return &ConnectionURI{
+		EnvPrefix:         CeleryBrokerEnvPrefix,
+		Template:          uri,
+		CredentialsSecret: creds.CredentialsSecret,
+		UsernameOptional:  usernameOptional,
+	}, nil
+}
+
+// GetResultBackendURI returns the result backend url, it defaults to the metadata database.
+// It returns nil when the metadata database is read from the credentials secret,
+// airflow then prefixes sql_alchemy_conn with db+ itself.
+func (c *CeleryBroker) GetResultBackendURI() (*ConnectionURI, error) {
+	if redis := c.getResultBackendRedis(); redis != nil {
+		return &ConnectionURI{
+			EnvPrefix:         CeleryResultBackendEnvPrefix,
+			Template:          getRedisURI(redis),
+			CredentialsSecret: redis.CredentialsSecret,
+			UsernameOptional:  true,
+		}, nil
+	}
+	if c.MetadataDatabase != nil {
+		return c.MetadataDatabase.GetURI(CeleryResultBackendEnvPrefix, "db+")
+	}
+	return nil, nil
+}
+
+func getRedisURI(redis *airflowv1alpha1.RedisSpec) string {
+	scheme := "redis"
+	if redis.TLS != nil {
+		scheme = "rediss"
+	}
+	port := redis.Port
+	if port == 0 {
+		port = DefaultRedisPort
+	}
+	return scheme + "://" + credentialsPlaceholder(redis.CredentialsSecret) + redis.Host + ":" + strconv.Itoa(int(port)) + "/" + strconv.Itoa(int(redis.DB))
+}
+
+// GetCeleryWorkerQueues returns the queues the workers consume, airflow sends tasks
+// without queue to its default queue.
+func GetCeleryWorkerQueues(config *airflowv1alpha1.CeleryConfigSpec) []string {
+	if config == nil || len(config.Queues) == 0 {
+		return []string{DefaultCeleryQueue}
+	}
+	return config.Queues
+}
+
+// getCeleryWorkerArgs returns the arguments of airflow celery worker, celery expects autoscale as max,min
+func getCeleryWorkerArgs(config *airflowv1alpha1.CeleryConfigSpec) string {
+	if config == nil {
+		return ""
+	}
+	var args []string
+	if len(config.Queues) > 0 {
+		args = append(args, "--queues", strings.Join(config.Queues, ","))
+	}
+	if config.Autoscale != nil {
+		args = append(args, "--autoscale", strconv.Itoa(int(config.Autoscale.Max))+","+strconv.Itoa(int(config.Autoscale.Min)))
+	} else if config.Concurrency != nil {
+		args = append(args, "--concurrency", strconv.Itoa(int(*config.Concurrency)))
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return " " + strings.Join(args, " ")
+}
+
+// getCeleryWorkerLifecycle stops the worker consuming its queues before kubernetes sends TERM,
+// the worker then finishes its running tasks in a warm shutdown within the termination grace period.
+func getCeleryWorkerLifecycle(queues []string) *corev1.Lifecycle {
+	script := "for queue in " + strings.Join(queues, " ") + "; do " +
+		"celery --app " + CeleryApp + " control cancel_consumer \"${queue}\" --destination celery@${HOSTNAME} || true; " +
+		"done"
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"/bin/bash", "-c", script}},
+		},
+	}
+}
+
+// GetEnvVars returns the AIRFLOW__CELERY__* env vars
+func (c *CeleryBroker) GetEnvVars() ([]corev1.EnvVar, error) {
+	broker, err := c.GetBrokerURI()
+	if err != nil {
+		return nil, err
+	}
+	envs := broker.GetEnvVars("AIRFLOW__CELERY__BROKER_URL")
+
+	resultBackend, err := c.GetResultBackendURI()
+	if err != nil {
+		return nil, err
+	}
+	if resultBackend != nil {
+		envs = append(envs, resultBackend.GetEnvVars("AIRFLOW__CELERY__RESULT_BACKEND")...)
+	}
+
+	envs = append(envs, corev1.EnvVar{
+		Name:  "AIRFLOW__CELERY__CELERY_CONFIG_OPTIONS",
+		Value: strings.TrimSuffix(CeleryConfigFileName, ".py") + ".CELERY_CONFIG",
+	})
+	return envs, nil
+}
+
+// GetConfig returns celery_config.py, it extends the default celery config of airflow
+func (c *CeleryBroker) GetConfig() (string, error) {
+	creds, err := c.getBrokerCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	cfg := `
+import os
+import ssl
+from copy import deepcopy
+
+try:
+	from airflow.providers.celery.executors.default_celery import DEFAULT_CELERY_CONFIG
+except ImportError:
+	from airflow.config_templates.default_celery import DEFAULT_CELERY_CONFIG
+
+CELERY_CONFIG = deepcopy(DEFAULT_CELERY_CONFIG)
+`
+
+	if creds.TLS != nil {
+		caFile := ""
+		if creds.TLS.CASecretClass != "" {
+			caFile = path.Join(CeleryBrokerCAPath, "ca.crt")
+		}
+		if c.Broker.Rabbitmq != nil {
+			cfg += `
+CELERY_CONFIG['broker_use_ssl'] = ` + getSSLOptions("", caFile)
+		} else {
+			cfg += `
+CELERY_CONFIG['broker_use_ssl'] = ` + getSSLOptions("ssl_", caFile)
+		}
+	}
+
+	if sentinel := c.Broker.RedisSentinel; sentinel != nil {
+		cfg += `
+CELERY_CONFIG.setdefault('broker_transport_options', {})
+CELERY_CONFIG['broker_transport_options']['master_name'] = '` + sentinel.MasterName + `'
+`
+		sentinelKwargs := make([]string, 0)
+		if sentinel.CredentialsSecret != "" {
+			broker, err := c.GetBrokerURI()
+			if err != nil {
+				return "", err
+			}
+			sentinelKwargs = append(sentinelKwargs,
+				`'username': os.environ.get('`+broker.GetUsernameEnvName()+`') or None`,
+				`'password': os.environ['`+broker.GetPasswordEnvName()+`']`,
+			)
+		}
+		if sentinel.TLS != nil {
+			sentinelKwargs = append(sentinelKwargs, `'ssl': True`)
+			if sentinel.TLS.CASecretClass != "" {
+				sentinelKwargs = append(sentinelKwargs, `'ssl_ca_certs': '`+path.Join(CeleryBrokerCAPath, "ca.crt")+`'`)
+			}
+		}
+		if len(sentinelKwargs) > 0 {
+			cfg += `CELERY_CONFIG['broker_transport_options']['sentinel_kwargs'] = {` + strings.Join(sentinelKwargs, ", ") + `}
+`
+		}
+	}
+
+	if redis := c.getResultBackendRedis(); redis != nil && redis.TLS != nil {
+		caFile := ""
+		if redis.TLS.CASecretClass != "" {
+			caFile = path.Join(CeleryResultBackendCAPath, "ca.crt")
+		}
+		cfg += `
+CELERY_CONFIG['redis_backend_use_ssl'] = ` + getSSLOptions("ssl_", caFile)
+	}
+
+	return util.IndentTab4Spaces(cfg), nil
+}
+
+// getSSLOptions renders the ssl options dict, redis prefixes the keys with ssl_
+func getSSLOptions(prefix, caFile string) string {
+	options := "{'" + prefix + "cert_reqs': ssl.CERT_REQUIRED"
+	if caFile != "" {
+		options += ", '" + prefix + "ca_certs': '" + caFile + "'"
+	}
+	return options + "}\n"
+}
+
+// GetVolumes returns the secret-operator volumes of the broker and result backend CAs
+func (c *CeleryBroker) GetVolumes() []corev1.Volume {
+	volumes := make([]corev1.Volume, 0)
+	if secretClass := c.getBrokerCASecretClass(); secretClass != "" {
+		volume := builder.NewSecretOperatorVolume(CeleryBrokerVolumeName, secretClass)
+		volume.SetFormatName(constants.TLSPEM)
+		volumes = append(volumes, *volume.Builde())
+	}
+	if secretClass := c.getResultBackendCASecretClass(); secretClass != "" {
+		volume := builder.NewSecretOperatorVolume(CeleryResultBackendVolumeName, secretClass)
+		volume.SetFormatName(constants.TLSPEM)
+		volumes = append(volumes, *volume.Builde())
+	}
+	// the metadata database CA is mounted together with the database settings
+	return volumes
+}
+
+func (c *CeleryBroker) GetVolumeMounts() []corev1.VolumeMount {
+	mounts := make([]corev1.VolumeMount, 0)
+	if c.getBrokerCASecretClass() != "" {
+		mounts = append(mounts, corev1.VolumeMount{Name: CeleryBrokerVolumeName, MountPath: CeleryBrokerCAPath})
+	}
+	if c.getResultBackendCASecretClass() != "" {
+		mounts = append(mounts, corev1.VolumeMount{Name: CeleryResultBackendVolumeName, MountPath: CeleryResultBackendCAPath})
+	}
+	return mounts
+}
+
+func (c *CeleryBroker) getBrokerCASecretClass() string {
+	creds, err := c.getBrokerCredentials()
+	if err != nil || creds.TLS == nil {
+		return ""
+	}
+	return creds.TLS.CASecretClass
+}
+
+func (c *CeleryBroker) getResultBackendCASecretClass() string {
+	if redis := c.getResultBackendRedis(); redis != nil && redis.TLS != nil {
+		return redis.TLS.CASecretClass
+	}
+	return ""
+}
@@ -1,5 +1,9 @@
 package commons
 
+import (
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
 type ExecutorType int32
 
 const (
@@ -20,3 +24,15 @@ func GetExecutorName(executor ExecutorType) string {
 		return "UnknownExecutor"
 	}
 }
+
+// GetWebserverServiceName returns the name of the service spanning every webserver role group,
+// airflow 3 task runners reach the execution api through it.
+func GetWebserverServiceName(clusterName string) string {
+	return clusterName + "-" + string(airflowv1alpha1.WebserversRoleName)
+}
+
+// GetKubernetesExecutorResourceName returns the name shared by the pod template ConfigMap,
+// ServiceAccount, Role and RoleBinding of the kubernetes executor role.
+func GetKubernetesExecutorResourceName(clusterName string) string {
+	return clusterName + "-" + string(airflowv1alpha1.KubernetesExecutorsRoleName)
+}
@@ -27,6 +27,7 @@ func NewConfigReconciler(
 	roleGroupConfig *airflowv1alpha1.ConfigSpec,
 	roleGroupInfo reconciler.RoleGroupInfo,
 	auth *Authentication,
+	celery *CeleryBroker,
 	options ...builder.Option,
 ) *reconciler.SimpleResourceReconciler[builder.ConfigBuilder] {
 
@@ -36,6 +37,7 @@ func NewConfigReconciler(
 		clusterConfig,
 		roleGroupConfig,
 		auth,
+		celery,
 		options...,
 	)
 	return reconciler.NewSimpleResourceReconciler[builder.ConfigBuilder](
@@ -51,6 +53,7 @@ type ConfigMapBuilder struct {
 	ClusterConfig   *airflowv1alpha1.ClusterConfigSpec
 	RoleGroupConfig *airflowv1alpha1.ConfigSpec
 	Auth            *Authentication
+	Celery          *CeleryBroker
 }
 
 // NewConfigMapBuilder returns a new ConfigMapBuilder
@@ -60,6 +63,7 @@ func NewConfigMapBuilder(
 	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
 	roleGroupConfig *airflowv1alpha1.ConfigSpec,
 	auth *Authentication,
+	celery *CeleryBroker,
 	options ...builder.Option,
 ) *ConfigMapBuilder {
 	return &ConfigMapBuilder{
@@ -71,6 +75,7 @@ func NewConfigMapBuilder(
 		ClusterConfig:   clusterConfig,
 		RoleGroupConfig: roleGroupConfig,
 		Auth:            auth,
+		Celery:          celery,
 	}
 }
 
@@ -92,6 +97,14 @@ func (b *ConfigMapBuilder) Build(ctx context.Context) (ctrlclient.Object, error)
 	b.AddItem("log_config.py", loggingConfig)
 	b.AddItem("vector.yaml", vectorConfig)
 
+	if b.Celery != nil {
+		celeryConfig, err := b.Celery.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+		b.AddItem(CeleryConfigFileName, celeryConfig)
+	}
+
 	return b.GetObject(), nil
 }
 
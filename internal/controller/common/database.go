@@ -0,0 +1,219 @@
+package commons
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	DefaultPostgresqlPort = 5432
+	DefaultMysqlPort      = 3306
+
+	// keys of the secret referenced by DatabaseConnectionSpec.CredentialsSecret
+	DatabaseCredentialsUsernameKey = "username"
+	DatabaseCredentialsPasswordKey = "password"
+
+	MetadataDatabaseVolumeName = "metadata-db-ca"
+	MetadataDatabaseEnvPrefix  = "METADATA_DB"
+)
+
+var MetadataDatabaseCAPath = path.Join(constants.KubedoopSecretDir, "metadata-db")
+
+// ConnectionURI renders a connection URI whose credentials are only known in the pod.
+// The URI is stored with {username} and {password} placeholders, and a python one-liner
+// fills in the url-quoted credentials read from the secret. Airflow runs the command
+// for every option set with an _CMD suffix, so passwords never end up in a URI.
+type ConnectionURI struct {
+	// EnvPrefix is the prefix of the env vars holding the template and credentials
+	EnvPrefix string
+	Template  string
+	// CredentialsSecret is empty when the server needs no authentication,
+	// the template is then used as is.
+	CredentialsSecret string
+	// UsernameOptional allows the secret to only contain a password, e.g. for redis
+	UsernameOptional bool
+}
+
+// GetEnvVars returns the env vars setting the airflow option, e.g. AIRFLOW__DATABASE__SQL_ALCHEMY_CONN
+func (c *ConnectionURI) GetEnvVars(option string) []corev1.EnvVar {
+	if c.CredentialsSecret == "" {
+		return []corev1.EnvVar{{Name: option, Value: c.Template}}
+	}
+
+	return []corev1.EnvVar{
+		{
+			Name:  c.EnvPrefix + "_URI",
+			Value: c.Template,
+		},
+		{
+			Name: c.GetUsernameEnvName(),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  DatabaseCredentialsUsernameKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: c.CredentialsSecret},
+					Optional:             ptr.To(c.UsernameOptional),
+				},
+			},
+		},
+		{
+			Name: c.GetPasswordEnvName(),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  DatabaseCredentialsPasswordKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: c.CredentialsSecret},
+				},
+			},
+		},
+		{
+			Name:  option + "_CMD",
+			Value: c.GetCommand(),
+		},
+	}
+}
+
+func (c *ConnectionURI) GetUsernameEnvName() string {
+	return c.EnvPrefix + "_USERNAME"
+}
+
+func (c *ConnectionURI) GetPasswordEnvName() string {
+	return c.EnvPrefix + "_PASSWORD"
+}
+
+// GetCommand returns the command printing the URI. Airflow splits it with shlex,
+// so the python code is passed as a single double-quoted argument.
+func (c *ConnectionURI) GetCommand() string {
+	return fmt.Sprintf(
+		`python3 -c "import os; from urllib.parse import quote; e = os.environ; `+
+			`print(e['%[1]s_URI'].format(username=quote(e.get('%[1]s_USERNAME', ''), safe=''), password=quote(e['%[1]s_PASSWORD'], safe='')))"`,
+		c.EnvPrefix,
+	)
+}
+
+// credentialsPlaceholder returns the userinfo part of a URI template
+func credentialsPlaceholder(credentialsSecret string) string {
+	if credentialsSecret == "" {
+		return ""
+	}
+	return "{username}:{password}@"
+}
+
+// MetadataDatabase renders the env, volumes and mounts airflow needs to connect
+// to the metadata database of MetadataDatabaseSpec.
+type MetadataDatabase struct {
+	Spec *airflowv1alpha1.MetadataDatabaseSpec
+}
+
+// NewMetadataDatabase returns nil when the cluster has no metadataDatabase,
+// the connection is then read from the credentials secret.
+func NewMetadataDatabase(clusterConfig *airflowv1alpha1.ClusterConfigSpec) *MetadataDatabase {
+	if clusterConfig == nil || clusterConfig.MetadataDatabase == nil {
+		return nil
+	}
+	return &MetadataDatabase{Spec: clusterConfig.MetadataDatabase}
+}
+
+func (m *MetadataDatabase) getConnection() (*airflowv1alpha1.DatabaseConnectionSpec, error) {
+	switch {
+	case m.Spec.Postgresql != nil:
+		return &m.Spec.Postgresql.DatabaseConnectionSpec, nil
+	case m.Spec.Mysql != nil:
+		return &m.Spec.Mysql.DatabaseConnectionSpec, nil
+	default:
+		return nil, fmt.Errorf("metadata database has neither postgresql nor mysql set")
+	}
+}
+
+// GetURI returns the connection URI with the given scheme prefix added to the driver,
+// e.g. "db+" for the celery result backend. The env prefix tells several URIs of the same database apart.
+func (m *MetadataDatabase) GetURI(envPrefix, schemePrefix string) (*ConnectionURI, error) {
+	conn, err := m.getConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	var scheme string
+	var port int32
+	query := url.Values{}
+	caFile := path.Join(MetadataDatabaseCAPath, "ca.crt")
+
+	if pg := m.Spec.Postgresql; pg != nil {
+		scheme, port = "postgresql+psycopg2", DefaultPostgresqlPort
+		if pg.SSLMode != "" {
+			query.Set("sslmode", pg.SSLMode)
+		}
+		if conn.CASecretClass != "" {
+			query.Set("sslrootcert", caFile)
+		}
+	} else {
+		scheme, port = "mysql+mysqldb", DefaultMysqlPort
+		if m.Spec.Mysql.SSLMode != "" {
+			query.Set("ssl_mode", m.Spec.Mysql.SSLMode)
+		}
+		if conn.CASecretClass != "" {
+			query.Set("ssl_ca", caFile)
+		}
+	}
+	if conn.Port != 0 {
+		port = conn.Port
+	}
+
+	uri := schemePrefix + scheme + "://" + credentialsPlaceholder(conn.CredentialsSecret) + conn.Host + ":" + strconv.Itoa(int(port)) + "/" + conn.Database
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	return &ConnectionURI{
+		EnvPrefix:         envPrefix,
+		Template:          uri,
+		CredentialsSecret: conn.CredentialsSecret,
+	}, nil
+}
+
+// GetEnvVars returns the env vars setting AIRFLOW__DATABASE__SQL_ALCHEMY_CONN
+func (m *MetadataDatabase) GetEnvVars() ([]corev1.EnvVar, error) {
+	uri, err := m.GetURI(MetadataDatabaseEnvPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+	return uri.GetEnvVars("AIRFLOW__DATABASE__SQL_ALCHEMY_CONN"), nil
+}
+
+func (m *MetadataDatabase) getCASecretClass() string {
+	conn, err := m.getConnection()
+	if err != nil {
+		return ""
+	}
+	return conn.CASecretClass
+}
+
+// GetVolumes returns the secret-operator volume of the CA, if any
+func (m *MetadataDatabase) GetVolumes() []corev1.Volume {
+	secretClass := m.getCASecretClass()
+	if secretClass == "" {
+		return nil
+	}
+	volume := builder.NewSecretOperatorVolume(MetadataDatabaseVolumeName, secretClass)
+	volume.SetFormatName(constants.TLSPEM)
+	return []corev1.Volume{*volume.Builde()}
+}
+
+func (m *MetadataDatabase) GetVolumeMounts() []corev1.VolumeMount {
+	if m.getCASecretClass() == "" {
+		return nil
+	}
+	return []corev1.VolumeMount{
+		{
+			Name:      MetadataDatabaseVolumeName,
+			MountPath: MetadataDatabaseCAPath,
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package commons
+
+import (
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+// roleDefaultGracefulShutdownTimeouts are the termination grace periods of the statefulset roles.
+// Celery workers finish their running tasks before they exit, so they get the longest period.
+var roleDefaultGracefulShutdownTimeouts = map[airflowv1alpha1.RoleName]string{
+	airflowv1alpha1.SchedulersRoleName:      "2m",
+	airflowv1alpha1.WebserversRoleName:      "2m",
+	airflowv1alpha1.CeleryExecutorsRoleName: "10m",
+	airflowv1alpha1.TriggerersRoleName:      "2m",
+	airflowv1alpha1.DagProcessorsRoleName:   "2m",
+	airflowv1alpha1.FlowerRoleName:          "30s",
+}
+
+// roleDefaultResources are the resources of the main container of each role
+var roleDefaultResources = map[airflowv1alpha1.RoleName]commonsv1alpha1.ResourcesSpec{
+	airflowv1alpha1.SchedulersRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("500m"), Max: resource.MustParse("1")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("1Gi")},
+	},
+	airflowv1alpha1.WebserversRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("500m"), Max: resource.MustParse("1")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("2Gi")},
+	},
+	airflowv1alpha1.CeleryExecutorsRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("500m"), Max: resource.MustParse("1")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("2Gi")},
+	},
+	airflowv1alpha1.TriggerersRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("100m"), Max: resource.MustParse("500m")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("1Gi")},
+	},
+	airflowv1alpha1.DagProcessorsRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("500m"), Max: resource.MustParse("1")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("1Gi")},
+	},
+	airflowv1alpha1.FlowerRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("100m"), Max: resource.MustParse("500m")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("512Mi")},
+	},
+	airflowv1alpha1.KubernetesExecutorsRoleName: {
+		CPU:    &commonsv1alpha1.CPUResource{Min: resource.MustParse("100m"), Max: resource.MustParse("1")},
+		Memory: &commonsv1alpha1.MemoryResource{Limit: resource.MustParse("1Gi")},
+	},
+}
+
+// DefaultConfig returns a copy of the merged role group config with the defaults of the role set.
+// The controller applies it to every role group, so the defaults do not depend on the mutating webhook.
+func DefaultConfig(roleName airflowv1alpha1.RoleName, config *airflowv1alpha1.ConfigSpec) *airflowv1alpha1.ConfigSpec {
+	if config == nil {
+		config = &airflowv1alpha1.ConfigSpec{}
+	} else {
+		config = config.DeepCopy()
+	}
+	if config.RoleGroupConfigSpec == nil {
+		config.RoleGroupConfigSpec = &commonsv1alpha1.RoleGroupConfigSpec{}
+	}
+
+	if config.GracefulShutdownTimeout == "" {
+		config.GracefulShutdownTimeout = roleDefaultGracefulShutdownTimeouts[roleName]
+	}
+
+	defaults := roleDefaultResources[roleName]
+	if config.Resources == nil {
+		config.Resources = &commonsv1alpha1.ResourcesSpec{}
+	}
+	if config.Resources.CPU == nil {
+		config.Resources.CPU = defaults.CPU.DeepCopy()
+	} else {
+		if config.Resources.CPU.Min.IsZero() {
+			config.Resources.CPU.Min = defaults.CPU.Min.DeepCopy()
+		}
+		if config.Resources.CPU.Max.IsZero() {
+			config.Resources.CPU.Max = defaults.CPU.Max.DeepCopy()
+		}
+	}
+	if config.Resources.Memory == nil {
+		config.Resources.Memory = defaults.Memory.DeepCopy()
+	} else if config.Resources.Memory.Limit.IsZero() {
+		config.Resources.Memory.Limit = defaults.Memory.Limit.DeepCopy()
+	}
+
+	if config.Logging == nil {
+		config.Logging = &commonsv1alpha1.LoggingSpec{}
+	}
+	if config.Logging.Containers == nil {
+		config.Logging.Containers = map[string]commonsv1alpha1.LoggingConfigSpec{}
+	}
+	logging := config.Logging.Containers[string(roleName)]
+	if logging.Console == nil {
+		logging.Console = &commonsv1alpha1.LogLevelSpec{Level: DefaultLogLevel}
+	}
+	if logging.File == nil {
+		logging.File = &commonsv1alpha1.LogLevelSpec{Level: DefaultLogLevel}
+	}
+	if logging.Loggers == nil {
+		logging.Loggers = map[string]*commonsv1alpha1.LogLevelSpec{}
+	}
+	if _, ok := logging.Loggers["root"]; !ok {
+		logging.Loggers["root"] = &commonsv1alpha1.LogLevelSpec{Level: DefaultLogLevel}
+	}
+	config.Logging.Containers[string(roleName)] = logging
+
+	return config
+}
@@ -0,0 +1,69 @@
+package commons
+
+import (
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	// FlowerPort is the port of the celery flower UI
+	FlowerPort = 5555
+
+	// keys of the secret referenced by FlowerSpec.CredentialsSecret
+	FlowerCredentialsUsernameKey = "username"
+	FlowerCredentialsPasswordKey = "password"
+
+	FlowerEnvPrefix = "FLOWER"
+)
+
+// GetFlowerEnvVars returns the env vars enabling the basic auth of flower. Kubernetes expands
+// the credentials into AIRFLOW__CELERY__FLOWER_BASIC_AUTH, so they are not part of the command.
+func GetFlowerEnvVars(flower *airflowv1alpha1.FlowerSpec) []corev1.EnvVar {
+	if flower == nil || flower.CredentialsSecret == "" {
+		return nil
+	}
+	usernameEnv, passwordEnv := FlowerEnvPrefix+"_USERNAME", FlowerEnvPrefix+"_PASSWORD"
+	return []corev1.EnvVar{
+		{
+			Name: usernameEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  FlowerCredentialsUsernameKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: flower.CredentialsSecret},
+				},
+			},
+		},
+		{
+			Name: passwordEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  FlowerCredentialsPasswordKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: flower.CredentialsSecret},
+				},
+			},
+		},
+		{
+			Name:  "AIRFLOW__CELERY__FLOWER_BASIC_AUTH",
+			Value: "$(" + usernameEnv + "):$(" + passwordEnv + ")",
+		},
+		{
+			Name:  "AIRFLOW__CELERY__FLOWER_PORT",
+			Value: strconv.Itoa(FlowerPort),
+		},
+	}
+}
+
+// GetFlowerListenerClass returns the listener class exposing flower, it defaults to the one of the cluster
+func GetFlowerListenerClass(clusterConfig *airflowv1alpha1.ClusterConfigSpec, flower *airflowv1alpha1.FlowerSpec) constants.ListenerClass {
+	if flower != nil && flower.ListenerClass != "" {
+		return flower.ListenerClass
+	}
+	if clusterConfig == nil {
+		return ""
+	}
+	return clusterConfig.ListenerClass
+}
@@ -0,0 +1,207 @@
+package commons
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	DefaultGitSyncImage = "registry.k8s.io/git-sync/git-sync:v4.4.0"
+
+	GitSyncVolumeName = "git-sync"
+
+	GitSyncInitContainerName    = "git-sync-init"
+	GitSyncSidecarContainerName = "git-sync"
+
+	// keys of the secret referenced by DagsGitSyncSpec.CrdentialsSecret
+	GitSyncCredentialsUserKey     = "user"
+	GitSyncCredentialsPasswordKey = "password"
+)
+
+var (
+	// DagsRoot is the DAGs folder when no repo is synced
+	DagsRoot = path.Join(AirflowHome, "dags")
+
+	// GitSyncRoot is the mount path of the volume shared by git-sync and airflow.
+	// Every repo is checked out in its own directory under GitSyncRoot/repos, and
+	// git-sync links the current revision to GitSyncRoot/dags/<name>. Keeping the
+	// checkouts out of the DAGs folder stops airflow from parsing old worktrees.
+	GitSyncRoot     = path.Join(AirflowHome, "git-sync")
+	GitSyncDagsRoot = path.Join(GitSyncRoot, "dags")
+)
+
+// GitSync renders the git-sync containers for a DagsGitSyncSpec.
+// The init container clones the repo once so DAGs are available when airflow starts,
+// the sidecar keeps the checkout up to date.
+type GitSync struct {
+	Index int
+	Spec  *airflowv1alpha1.DagsGitSyncSpec
+	Image *util.Image
+}
+
+func NewGitSync(index int, spec *airflowv1alpha1.DagsGitSyncSpec) *GitSync {
+	return &GitSync{
+		Index: index,
+		Spec:  spec,
+		Image: &util.Image{
+			Custom:     DefaultGitSyncImage,
+			PullPolicy: corev1.PullIfNotPresent,
+		},
+	}
+}
+
+// GetName returns the directory name of the repo, it defaults to repo-<index>
+func (g *GitSync) GetName() string {
+	if g.Spec.Name != "" {
+		return g.Spec.Name
+	}
+	return "repo-" + strconv.Itoa(g.Index)
+}
+
+// GetLink returns the path git-sync links to the checked out revision
+func (g *GitSync) GetLink() string {
+	return path.Join(GitSyncDagsRoot, g.GetName())
+}
+
+// GetDagFolder returns the folder of the DAGs inside the checkout
+func (g *GitSync) GetDagFolder() string {
+	return path.Join(g.GetLink(), g.Spec.GitFolder)
+}
+
+func (g *GitSync) GetInitContainerName() string {
+	return GitSyncInitContainerName + "-" + strconv.Itoa(g.Index)
+}
+
+func (g *GitSync) GetSidecarContainerName() string {
+	return GitSyncSidecarContainerName + "-" + strconv.Itoa(g.Index)
+}
+
+func (g *GitSync) GetInitContainer() *corev1.Container {
+	return g.getContainer(g.GetInitContainerName(), true)
+}
+
+func (g *GitSync) GetSidecarContainer() *corev1.Container {
+	return g.getContainer(g.GetSidecarContainerName(), false)
+}
+
+func (g *GitSync) getContainer(name string, oneTime bool) *corev1.Container {
+	container := builder.NewContainer(name, g.Image)
+	container.SetArgs(g.getArgs(oneTime))
+	container.AddEnvVars(g.getEnvVars())
+	container.AddVolumeMounts([]corev1.VolumeMount{GetGitSyncVolumeMount()})
+	return container.Build()
+}
+
+// getArgs returns the git-sync flags, entries of gitSyncConf take precedence over
+// the flags derived from the spec.
+func (g *GitSync) getArgs(oneTime bool) []string {
+	flags := map[string]string{
+		"repo": g.Spec.Repo,
+		"root": path.Join(GitSyncRoot, "repos", g.GetName()),
+		"link": g.GetLink(),
+	}
+	if g.Spec.Branch != "" {
+		flags["ref"] = g.Spec.Branch
+	}
+	if g.Spec.Depth != nil {
+		flags["depth"] = strconv.Itoa(int(*g.Spec.Depth))
+	}
+	if g.Spec.Wait != nil {
+		flags["period"] = strconv.Itoa(int(*g.Spec.Wait)) + "s"
+	}
+	if oneTime {
+		flags["one-time"] = "true"
+	}
+
+	for key, value := range g.Spec.GitSyncConf {
+		flags[strings.TrimLeft(key, "-")] = value
+	}
+
+	args := make([]string, 0, len(flags))
+	for _, key := range slices.Sorted(maps.Keys(flags)) {
+		args = append(args, "--"+key+"="+flags[key])
+	}
+	return args
+}
+
+func (g *GitSync) getEnvVars() []corev1.EnvVar {
+	if g.Spec.CrdentialsSecret == "" {
+		return nil
+	}
+
+	return []corev1.EnvVar{
+		{
+			Name: "GITSYNC_USERNAME",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: GitSyncCredentialsUserKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: g.Spec.CrdentialsSecret,
+					},
+				},
+			},
+		},
+		{
+			Name: "GITSYNC_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: GitSyncCredentialsPasswordKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: g.Spec.CrdentialsSecret,
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetGitSyncs returns a git-sync for every repo of the cluster
+func GetGitSyncs(clusterConfig *airflowv1alpha1.ClusterConfigSpec) []*GitSync {
+	if clusterConfig == nil {
+		return nil
+	}
+	gitSyncs := make([]*GitSync, 0, len(clusterConfig.DagsGitSync))
+	for i := range clusterConfig.DagsGitSync {
+		gitSyncs = append(gitSyncs, NewGitSync(i, &clusterConfig.DagsGitSync[i]))
+	}
+	return gitSyncs
+}
+
+// GetDagsFolder returns the folder airflow loads DAGs from. A single repo keeps
+// its gitFolder as DAGs folder, several repos share the common root of their links,
+// the webhook rejects a gitFolder for them.
+func GetDagsFolder(gitSyncs []*GitSync) string {
+	switch len(gitSyncs) {
+	case 0:
+		return DagsRoot
+	case 1:
+		return gitSyncs[0].GetDagFolder()
+	default:
+		return GitSyncDagsRoot
+	}
+}
+
+func GetGitSyncVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: GitSyncVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+}
+
+func GetGitSyncVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      GitSyncVolumeName,
+		MountPath: GitSyncRoot,
+	}
+}
@@ -0,0 +1,164 @@
+package commons
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	listenersv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/listeners/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	ListenerVolumeName = "listener"
+
+	// DiscoveryWebserverURLKey is the key of the webserver url in the discovery ConfigMap
+	DiscoveryWebserverURLKey = "AIRFLOW_WEBSERVER_URL"
+)
+
+// GetListenerName returns the Listener shared by the pods of a webserver or flower role group.
+// listener-operator creates a Service named after the Listener, the suffix keeps it apart
+// from the Service of the role group.
+func GetListenerName(roleGroupFullName string) string {
+	return roleGroupFullName + "-listener"
+}
+
+// GetListenerVolume returns the listener-operator volume exposing a role group,
+// it is nil when no listener class is set.
+func GetListenerVolume(listenerClass constants.ListenerClass, roleGroupFullName string) *corev1.Volume {
+	if listenerClass == "" {
+		return nil
+	}
+	volume := builder.NewListenerOperatorVolume(ListenerVolumeName, string(listenerClass))
+	volume.SetListenerName(GetListenerName(roleGroupFullName))
+	return volume.Builde()
+}
+
+// GetListenerVolumeMount mounts the listener volume where listener-operator publishes the pod addresses
+func GetListenerVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      ListenerVolumeName,
+		MountPath: constants.KubedoopListenerDir,
+	}
+}
+
+// GetWebserverAddresses returns the address of every webserver role group, sorted by role group.
+// With a listener class the address is read from the Listener, a role group whose Listener
+// has no address yet is left out. Without a listener class the role group Service is used.
+func GetWebserverAddresses(
+	ctx context.Context,
+	client ctrlclient.Client,
+	namespace string,
+	clusterName string,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleGroups []string,
+) ([]airflowv1alpha1.WebserverAddressStatus, error) {
+	addresses := make([]airflowv1alpha1.WebserverAddressStatus, 0, len(roleGroups))
+	for _, roleGroup := range slices.Sorted(slices.Values(roleGroups)) {
+		info := reconciler.RoleGroupInfo{
+			RoleInfo: reconciler.RoleInfo{
+				ClusterInfo: reconciler.ClusterInfo{ClusterName: clusterName},
+				RoleName:    string(airflowv1alpha1.WebserversRoleName),
+			},
+			RoleGroupName: roleGroup,
+		}
+
+		if clusterConfig == nil || clusterConfig.ListenerClass == "" {
+			addresses = append(addresses, airflowv1alpha1.WebserverAddressStatus{
+				RoleGroup: roleGroup,
+				Address:   info.GetFullName() + "." + namespace + ".svc.cluster.local",
+				Port:      WebserverPort,
+			})
+			continue
+		}
+
+		listener := &listenersv1alpha1.Listener{}
+		err := client.Get(ctx, ctrlclient.ObjectKey{Namespace: namespace, Name: GetListenerName(info.GetFullName())}, listener)
+		if ctrlclient.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		for _, ingress := range listener.Status.IngressAddresses {
+			if port, ok := ingress.Ports["http"]; ok {
+				addresses = append(addresses, airflowv1alpha1.WebserverAddressStatus{
+					RoleGroup: roleGroup,
+					Address:   ingress.Address,
+					Port:      port,
+				})
+				break
+			}
+		}
+	}
+	return addresses, nil
+}
+
+func NewDiscoveryReconciler(
+	client *client.Client,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleGroups []string,
+	options ...builder.Option,
+) *reconciler.SimpleResourceReconciler[builder.ConfigBuilder] {
+	return reconciler.NewSimpleResourceReconciler[builder.ConfigBuilder](
+		client,
+		NewDiscoveryConfigMapBuilder(client, clusterConfig, roleGroups, options...),
+	)
+}
+
+var _ builder.ConfigBuilder = &DiscoveryConfigMapBuilder{}
+
+// DiscoveryConfigMapBuilder renders the discovery ConfigMap, named after the cluster,
+// that clients read the webserver url from. The url of the first role group is published,
+// it is left out until its Listener has an address. The cluster is reconciled again when
+// the webserver pods become ready, by then listener-operator assigned the address.
+type DiscoveryConfigMapBuilder struct {
+	builder.ConfigMapBuilder
+
+	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
+	RoleGroups    []string
+}
+
+func NewDiscoveryConfigMapBuilder(
+	client *client.Client,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleGroups []string,
+	options ...builder.Option,
+) *DiscoveryConfigMapBuilder {
+	opts := &builder.Options{}
+	for _, o := range options {
+		o(opts)
+	}
+
+	return &DiscoveryConfigMapBuilder{
+		ConfigMapBuilder: *builder.NewConfigMapBuilder(client, opts.ClusterName, options...),
+		ClusterConfig:    clusterConfig,
+		RoleGroups:       roleGroups,
+	}
+}
+
+func (b *DiscoveryConfigMapBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
+	addresses, err := GetWebserverAddresses(
+		ctx,
+		b.Client.GetCtrlClient(),
+		b.Client.GetOwnerNamespace(),
+		b.ClusterName,
+		b.ClusterConfig,
+		b.RoleGroups,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addresses) > 0 {
+		b.AddItem(DiscoveryWebserverURLKey, "http://"+addresses[0].Address+":"+strconv.Itoa(int(addresses[0].Port)))
+	}
+	return b.GetObject(), nil
+}
@@ -0,0 +1,227 @@
+package commons
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+const (
+	MigrationContainerName = "migrate"
+
+	// MigrationRequeueAfter is how often the job is checked while it is running
+	MigrationRequeueAfter = 10 * time.Second
+
+	// MigrationJobSpecHashAnnotation records the hash of the pod template the job was created with
+	MigrationJobSpecHashAnnotation = "airflow.kubedoop.dev/migration-spec-hash"
+)
+
+// GetMigrationJobName returns the name of the migration job of a product version,
+// e.g. airflow-db-migrate-2-10-2
+func GetMigrationJobName(clusterName, productVersion string) string {
+	return clusterName + "-db-migrate-" + strings.ReplaceAll(productVersion, ".", "-")
+}
+
+// GetMigrationJobPhase returns the phase and message of a migration job
+func GetMigrationJobPhase(job *batchv1.Job) (airflowv1alpha1.DatabaseMigrationPhase, string) {
+	if job.Status.Succeeded > 0 {
+		return airflowv1alpha1.DatabaseMigrationSucceeded, ""
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return airflowv1alpha1.DatabaseMigrationFailed, condition.Message
+		}
+	}
+	if job.Status.Active > 0 {
+		return airflowv1alpha1.DatabaseMigrationRunning, ""
+	}
+	return airflowv1alpha1.DatabaseMigrationPending, ""
+}
+
+var migrationLogger = ctrl.Log.WithName("migration")
+
+var _ reconciler.Reconciler = &MigrationJobReconciler{}
+
+// MigrationJobReconciler runs the database migration once per product version.
+// A job can not be updated, when the rendered pod template changes, e.g. the database
+// or credentials secret, the job is deleted and created again. Otherwise it is kept.
+// Reconcile requeues until the job succeeded, which holds back every resource
+// registered after it, so workloads never start against an unmigrated database.
+// A job which failed with the current spec is not retried, deleting it runs the migration again.
+type MigrationJobReconciler struct {
+	reconciler.GenericResourceReconciler[builder.JobBuilder]
+}
+
+func NewMigrationJobReconciler(
+	client *client.Client,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+	options ...builder.Option,
+) *MigrationJobReconciler {
+	jobBuilder := NewMigrationJobBuilder(client, clusterConfig, image, options...)
+	return &MigrationJobReconciler{
+		GenericResourceReconciler: *reconciler.NewGenericResourceReconciler[builder.JobBuilder](
+			client,
+			jobBuilder,
+		),
+	}
+}
+
+func (r *MigrationJobReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	obj, err := r.GetBuilder().Build(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	desired := obj.(*batchv1.Job)
+	hash, err := getMigrationJobSpecHash(desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if desired.Annotations == nil {
+		desired.Annotations = make(map[string]string)
+	}
+	desired.Annotations[MigrationJobSpecHashAnnotation] = hash
+
+	job := &batchv1.Job{}
+	if err := r.Client.GetCtrlClient().Get(ctx, ctrlclient.ObjectKeyFromObject(desired), job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err := r.Client.CreateDoesNotExist(ctx, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: MigrationRequeueAfter}, nil
+	}
+
+	if job.DeletionTimestamp != nil {
+		migrationLogger.Info("Waiting for stale database migration job to be deleted", "namespace", job.Namespace, "name", job.Name)
+		return ctrl.Result{RequeueAfter: MigrationRequeueAfter}, nil
+	}
+	if job.Annotations[MigrationJobSpecHashAnnotation] != hash {
+		migrationLogger.Info("Deleting stale database migration job", "namespace", job.Namespace, "name", job.Name)
+		if err := r.Client.GetCtrlClient().Delete(ctx, job, ctrlclient.PropagationPolicy(metav1.DeletePropagationBackground)); ctrlclient.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: MigrationRequeueAfter}, nil
+	}
+
+	switch phase, message := GetMigrationJobPhase(job); phase {
+	case airflowv1alpha1.DatabaseMigrationSucceeded:
+		return ctrl.Result{}, nil
+	case airflowv1alpha1.DatabaseMigrationFailed:
+		return ctrl.Result{}, fmt.Errorf("database migration job %s failed, delete it to run the migration again: %s", job.Name, message)
+	default:
+		migrationLogger.Info("Waiting for database migration", "namespace", job.Namespace, "name", job.Name)
+		return ctrl.Result{RequeueAfter: MigrationRequeueAfter}, nil
+	}
+}
+
+// getMigrationJobSpecHash returns the hash of the pod template of the job
+func getMigrationJobSpecHash(job *batchv1.Job) (string, error) {
+	data, err := json.Marshal(job.Spec.Template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+var _ builder.JobBuilder = &MigrationJobBuilder{}
+
+// MigrationJobBuilder builds the job migrating the metadata database and creating the admin user
+type MigrationJobBuilder struct {
+	builder.Job
+	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
+}
+
+func NewMigrationJobBuilder(
+	client *client.Client,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+	options ...builder.Option,
+) *MigrationJobBuilder {
+	opts := &builder.Options{}
+	for _, o := range options {
+		o(opts)
+	}
+
+	return &MigrationJobBuilder{
+		Job: *builder.NewGenericJobBuilder(
+			client,
+			GetMigrationJobName(opts.ClusterName, image.ProductVersion),
+			image,
+			nil,
+			nil,
+			options...,
+		).(*builder.Job),
+		ClusterConfig: clusterConfig,
+	}
+}
+
+func (b *MigrationJobBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
+	envs, err := getAirflowEnvVars(b.ClusterName, b.ClusterConfig, b.GetImage(), LocalExecutor, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the job has no config map, so airflow logs with its default config
+	envs = slices.DeleteFunc(envs, func(env corev1.EnvVar) bool {
+		return env.Name == "AIRFLOW__LOGGING__LOGGING_CONFIG_CLASS"
+	})
+	envs = append(envs, getAdminUserEnvVars(b.ClusterConfig.Credentials)...)
+
+	container := builder.NewContainer(MigrationContainerName, b.GetImage())
+	container.SetCommand([]string{"/bin/bash", "-euo", "pipefail", "-c"})
+	container.SetArgs([]string{getMigrationArgs(b.GetImage().ProductVersion)})
+	container.AddEnvVars(envs)
+
+	if metadataDatabase := NewMetadataDatabase(b.ClusterConfig); metadataDatabase != nil {
+		container.AddVolumeMounts(metadataDatabase.GetVolumeMounts())
+		b.AddVolumes(metadataDatabase.GetVolumes())
+	}
+
+	b.AddContainer(container.Build())
+	b.SetRestPolicy(ptr.To(corev1.RestartPolicyOnFailure))
+
+	return b.GetObject()
+}
+
+// getMigrationArgs migrates the database and creates the admin user, users create
+// leaves an existing user untouched, so the job can be run again. Airflow 3 provides
+// the users command through the FAB auth manager, which is configured for it.
+// Airflow before 2.7 migrates with db upgrade.
+func getMigrationArgs(productVersion string) string {
+	migrateCommand := "airflow db migrate"
+	if !HasDbMigrate(productVersion) {
+		migrateCommand = "airflow db upgrade"
+	}
+	return util.IndentTab4Spaces(`
+` + migrateCommand + `
+
+airflow users create \
+	--username "$` + EnvKeyAdminUserName + `" \
+	--firstname "$` + ENVKeyAdminFirstName + `" \
+	--lastname "$` + EnvKeyAdminLastName + `" \
+	--email "$` + EnvKeyAdminEmail + `" \
+	--password "$` + EnvKeyAdminPassword + `" \
+	--role "Admin"
+`)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commons
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+var _ = Describe("MigrationJobReconciler", func() {
+	var (
+		ctx           = context.Background()
+		c             *client.Client
+		clusterConfig *airflowv1alpha1.ClusterConfigSpec
+	)
+
+	key := ctrlclient.ObjectKey{Namespace: "default", Name: "airflow-db-migrate-2-10-2"}
+
+	BeforeEach(func() {
+		cluster := &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+		}
+		c = client.NewClient(fake.NewClientBuilder().WithScheme(scheme).Build(), cluster)
+		clusterConfig = &airflowv1alpha1.ClusterConfigSpec{Credentials: "airflow-credentials"}
+	})
+
+	reconcile := func() error {
+		r := NewMigrationJobReconciler(
+			c,
+			clusterConfig,
+			&util.Image{Repo: "quay.io/zncdatadev", ProductName: "airflow", ProductVersion: "2.10.2", KubedoopVersion: "0.0.0-dev"},
+			func(o *builder.Options) {
+				o.ClusterName = "airflow"
+			},
+		)
+		_, err := r.Reconcile(ctx)
+		return err
+	}
+
+	getJob := func() (*batchv1.Job, error) {
+		job := &batchv1.Job{}
+		return job, c.GetCtrlClient().Get(ctx, key, job)
+	}
+
+	fail := func() {
+		job, err := getJob()
+		Expect(err).NotTo(HaveOccurred())
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+		}
+		Expect(c.GetCtrlClient().Status().Update(ctx, job)).To(Succeed())
+	}
+
+	It("keeps a failed job until it is deleted", func() {
+		Expect(reconcile()).To(Succeed())
+		fail()
+
+		Expect(reconcile()).To(MatchError(ContainSubstring("delete it to run the migration again")))
+		job, err := getJob()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Annotations).To(HaveKey(MigrationJobSpecHashAnnotation))
+	})
+
+	It("creates the job again when the credentials change", func() {
+		Expect(reconcile()).To(Succeed())
+		fail()
+
+		clusterConfig.Credentials = "airflow-credentials-v2"
+		Expect(reconcile()).To(Succeed())
+		_, err := getJob()
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(reconcile()).To(Succeed())
+		job, err := getJob()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Status.Conditions).To(BeEmpty())
+	})
+
+	It("migrates with the db command of the airflow version", func() {
+		Expect(reconcile()).To(Succeed())
+		job, err := getJob()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("airflow db migrate"))
+
+		Expect(getMigrationArgs("2.6.3")).To(ContainSubstring("airflow db upgrade"))
+		Expect(getMigrationArgs("2.6.3")).NotTo(ContainSubstring("airflow db migrate"))
+		Expect(getMigrationArgs("3.0.2")).To(ContainSubstring("airflow db migrate"))
+	})
+})
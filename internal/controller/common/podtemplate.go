@@ -0,0 +1,216 @@
+package commons
+
+import (
+	"context"
+
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+// KubernetesExecutorBaseContainerName is the container name airflow expects in the pod template,
+// the task command is injected into this container.
+const KubernetesExecutorBaseContainerName = "base"
+
+func NewPodTemplateReconciler(
+	client *client.Client,
+	name string,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleConfig *airflowv1alpha1.ConfigSpec,
+	image *util.Image,
+	overrides *commonsv1alpha1.OverridesSpec,
+	options ...builder.Option,
+) *reconciler.SimpleResourceReconciler[builder.ConfigBuilder] {
+	podTemplateBuilder := NewPodTemplateConfigMapBuilder(
+		client,
+		name,
+		clusterConfig,
+		roleConfig,
+		image,
+		overrides,
+		options...,
+	)
+	return reconciler.NewSimpleResourceReconciler[builder.ConfigBuilder](
+		client,
+		podTemplateBuilder,
+	)
+}
+
+var _ builder.ConfigBuilder = &PodTemplateConfigMapBuilder{}
+
+// PodTemplateConfigMapBuilder renders the pod template used by the kubernetes executor
+// to launch task pods, together with the python config files the task pods import.
+type PodTemplateConfigMapBuilder struct {
+	ConfigMapBuilder
+
+	Image     *util.Image
+	Overrides *commonsv1alpha1.OverridesSpec
+}
+
+func NewPodTemplateConfigMapBuilder(
+	client *client.Client,
+	name string,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleConfig *airflowv1alpha1.ConfigSpec,
+	image *util.Image,
+	overrides *commonsv1alpha1.OverridesSpec,
+	options ...builder.Option,
+) *PodTemplateConfigMapBuilder {
+	return &PodTemplateConfigMapBuilder{
+		ConfigMapBuilder: *NewConfigMapBuilder(
+			client,
+			name,
+			clusterConfig,
+			roleConfig,
+			nil,
+			nil,
+			options...,
+		),
+		Image:     image,
+		Overrides: overrides,
+	}
+}
+
+func (b *PodTemplateConfigMapBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
+	airflowConfig, err := b.getAirflowConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	podTemplate, err := b.getPodTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	b.AddItem("webserver_config.py", airflowConfig)
+	b.AddItem("log_config.py", b.getLogging())
+	b.AddItem(KubernetesExecutorPodTemplateFileName, podTemplate)
+
+	return b.GetObject(), nil
+}
+
+func (b *PodTemplateConfigMapBuilder) getPodTemplate() (string, error) {
+	var roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
+	if b.RoleGroupConfig != nil {
+		roleGroupConfig = b.RoleGroupConfig.RoleGroupConfigSpec
+	}
+
+	workload := builder.NewBaseWorkloadBuilder(
+		b.Client,
+		b.Name,
+		b.Image,
+		b.Overrides,
+		roleGroupConfig,
+		func(o *builder.Options) {
+			o.ClusterName = b.ClusterName
+			o.RoleName = b.RoleName
+			o.Labels = b.GetLabels()
+			o.Annotations = b.GetAnnotations()
+		},
+	)
+
+	// Task pods import log_config and webserver_config from the python path,
+	// so the config files are mounted directly into the app config directory.
+	envs, err := getAirflowEnvVars(b.ClusterName, b.ClusterConfig, b.Image, LocalExecutor, nil)
+	if err != nil {
+		return "", err
+	}
+	container := builder.NewContainer(b.RoleName, b.Image)
+	container.AddEnvVars(envs)
+	container.AddVolumeMounts([]corev1.VolumeMount{
+		{
+			Name:      ConfigVolumeMountName,
+			MountPath: AppConfigPath,
+		},
+		{
+			Name:      LogVolumeMountName,
+			MountPath: constants.KubedoopLogDir,
+		},
+	})
+	workload.AddVolumes([]corev1.Volume{
+		{
+			Name: ConfigVolumeMountName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					DefaultMode:          &[]int32{420}[0],
+					LocalObjectReference: corev1.LocalObjectReference{Name: b.Name},
+					Items: []corev1.KeyToPath{
+						{Key: "webserver_config.py", Path: "webserver_config.py"},
+						{Key: "log_config.py", Path: "log_config.py"},
+					},
+				},
+			},
+		},
+		{
+			Name: LogVolumeMountName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					SizeLimit: ptr.To(resource.MustParse("500Mi")),
+				},
+			},
+		},
+	})
+
+	userVolumes, userVolumeMounts, err := GetUserVolumes(b.ClusterConfig)
+	if err != nil {
+		return "", err
+	}
+	workload.AddVolumes(userVolumes)
+	container.AddVolumeMounts(userVolumeMounts)
+
+	if metadataDatabase := NewMetadataDatabase(b.ClusterConfig); metadataDatabase != nil {
+		workload.AddVolumes(metadataDatabase.GetVolumes())
+		container.AddVolumeMounts(metadataDatabase.GetVolumeMounts())
+	}
+
+	// task pods only need a one-shot checkout of the DAGs
+	if gitSyncs := GetGitSyncs(b.ClusterConfig); len(gitSyncs) > 0 {
+		for _, gitSync := range gitSyncs {
+			workload.AddInitContainer(gitSync.GetInitContainer())
+		}
+		workload.AddVolumes([]corev1.Volume{GetGitSyncVolume()})
+		container.AddVolumeMounts([]corev1.VolumeMount{GetGitSyncVolumeMount()})
+	}
+
+	workload.AddContainer(container.Build())
+
+	template, err := workload.GetPodTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	// env and cli overrides are applied to the container named after the role,
+	// airflow requires it to be named base.
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == b.RoleName {
+			template.Spec.Containers[i].Name = KubernetesExecutorBaseContainerName
+		}
+	}
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+
+	out, err := yaml.Marshal(pod)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,130 @@
+package commons
+
+import (
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+// CeleryApp is the celery app of the airflow celery executor
+const CeleryApp = "airflow.providers.celery.executors.celery_executor.app"
+
+var (
+	// http probes answer quickly, the defaults follow the container builder of operator-go
+	httpStartupProbe   = corev1.Probe{InitialDelaySeconds: 4, PeriodSeconds: 6, TimeoutSeconds: 3, FailureThreshold: 30, SuccessThreshold: 1}
+	httpLivenessProbe  = corev1.Probe{PeriodSeconds: 10, TimeoutSeconds: 3, FailureThreshold: 3, SuccessThreshold: 1}
+	httpReadinessProbe = corev1.Probe{PeriodSeconds: 10, TimeoutSeconds: 3, FailureThreshold: 3, SuccessThreshold: 1}
+
+	// exec probes start the airflow or celery cli, which takes seconds to import
+	execStartupProbe   = corev1.Probe{PeriodSeconds: 10, TimeoutSeconds: 30, FailureThreshold: 30, SuccessThreshold: 1}
+	execLivenessProbe  = corev1.Probe{PeriodSeconds: 60, TimeoutSeconds: 30, FailureThreshold: 5, SuccessThreshold: 1}
+	execReadinessProbe = corev1.Probe{PeriodSeconds: 30, TimeoutSeconds: 30, FailureThreshold: 3, SuccessThreshold: 1}
+)
+
+// getWebserverHealthPath returns the health endpoint of the webserver, airflow 3 serves it from the api-server
+func getWebserverHealthPath(productVersion string) string {
+	if IsAirflow3(productVersion) {
+		return "/api/v2/monitor/health"
+	}
+	return "/health"
+}
+
+// getJobCheckProbeHandler checks the job of the local process heartbeats
+func getJobCheckProbeHandler(jobType string) *corev1.ProbeHandler {
+	return &corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"/bin/bash", "-c", "airflow jobs check --job-type " + jobType + " --local"},
+		},
+	}
+}
+
+// getProbeHandler returns the handler probing the main process of the role, nil when the role is not probed
+func (b *StatefulSetBuilder) getProbeHandler() *corev1.ProbeHandler {
+	switch airflowv1alpha1.RoleName(b.RoleName) {
+	case airflowv1alpha1.WebserversRoleName:
+		return &corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: getWebserverHealthPath(b.Image.ProductVersion),
+				Port: intstr.FromInt32(WebserverPort),
+			},
+		}
+	case airflowv1alpha1.SchedulersRoleName:
+		return getJobCheckProbeHandler("SchedulerJob")
+	case airflowv1alpha1.TriggerersRoleName:
+		return getJobCheckProbeHandler("TriggererJob")
+	case airflowv1alpha1.DagProcessorsRoleName:
+		return getJobCheckProbeHandler("DagProcessorJob")
+	case airflowv1alpha1.FlowerRoleName:
+		// flower asks for basic auth on every endpoint
+		return &corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(FlowerPort)},
+		}
+	case airflowv1alpha1.CeleryExecutorsRoleName:
+		return &corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/bash", "-c", "celery --app " + CeleryApp + " inspect ping --destination celery@${HOSTNAME}"},
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// newProbe returns the probe with the defaults overridden by the thresholds of the spec
+func newProbe(handler *corev1.ProbeHandler, defaults corev1.Probe, spec *airflowv1alpha1.ProbeSpec) *corev1.Probe {
+	probe := defaults.DeepCopy()
+	probe.ProbeHandler = *handler.DeepCopy()
+	if spec == nil {
+		return probe
+	}
+	if spec.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds != nil {
+		probe.PeriodSeconds = *spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold != nil {
+		probe.FailureThreshold = *spec.FailureThreshold
+	}
+	if spec.SuccessThreshold != nil {
+		probe.SuccessThreshold = *spec.SuccessThreshold
+	}
+	return probe
+}
+
+// setMainContainerProbes sets the startup, liveness and readiness probes of the main container.
+// The startup probe keeps the pod unready until airflow is up.
+func (b *StatefulSetBuilder) setMainContainerProbes(container builder.ContainerBuilder) {
+	handler := b.getProbeHandler()
+	if handler == nil {
+		return
+	}
+
+	startup, liveness, readiness := execStartupProbe, execLivenessProbe, execReadinessProbe
+	if handler.Exec == nil {
+		startup, liveness, readiness = httpStartupProbe, httpLivenessProbe, httpReadinessProbe
+	}
+
+	probes := &airflowv1alpha1.ProbesSpec{}
+	if b.Config != nil && b.Config.Probes != nil {
+		probes = b.Config.Probes
+	}
+
+	container.SetStartupProbe(newProbe(handler, startup, probes.Startup))
+	container.SetLivenessProbe(newProbe(handler, liveness, probes.Liveness))
+	container.SetReadinessProbe(newProbe(handler, readiness, probes.Readiness))
+}
+
+// setMetricContainerProbes probes the statsd exporter on its metrics port
+func setMetricContainerProbes(container builder.ContainerBuilder) {
+	handler := &corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(MetricsPort)},
+	}
+	container.SetLivenessProbe(newProbe(handler, httpLivenessProbe, nil))
+	container.SetReadinessProbe(newProbe(handler, httpReadinessProbe, nil))
+}
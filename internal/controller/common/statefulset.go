@@ -2,6 +2,7 @@ package commons
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
 	"strconv"
@@ -38,8 +39,18 @@ const (
 )
 
 const (
-	LogVolumeMountName    = "log"
-	ConfigVolumeMountName = "config"
+	// WebserverPort is the port of the webserver, or the api-server of airflow 3
+	WebserverPort = 8080
+	// MetricsPort is the port of the statsd exporter sidecar
+	MetricsPort = 9102
+	// TriggererLogServerPort is the port the triggerer serves task logs on
+	TriggererLogServerPort = 8794
+)
+
+const (
+	LogVolumeMountName                      = "log"
+	ConfigVolumeMountName                   = "config"
+	KubernetesExecutorPodTemplateVolumeName = "executor-pod-template"
 )
 
 const BashLibs = `
@@ -82,8 +93,9 @@ func NewStatefulSetReconciler(
 	replicas *int32,
 	stopped bool,
 	overrides *commonsv1alpha1.OverridesSpec,
-	roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec,
+	config *airflowv1alpha1.ConfigSpec,
 	executor ExecutorType,
+	celery *CeleryBroker,
 	auth *Authentication,
 	options ...builder.Option,
 ) (*reconciler.StatefulSet, error) {
@@ -96,8 +108,9 @@ func NewStatefulSetReconciler(
 		image,
 		ports,
 		overrides,
-		roleGroupConfig,
+		config,
 		executor,
+		celery,
 		auth,
 		options...,
 	)
@@ -115,8 +128,20 @@ var _ builder.StatefulSetBuilder = &StatefulSetBuilder{}
 type StatefulSetBuilder struct {
 	builder.StatefulSet
 	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
+	Config        *airflowv1alpha1.ConfigSpec
+	Ports         []corev1.ContainerPort
 	Executor      ExecutorType
+	Celery        *CeleryBroker
 	Auth          *Authentication
+	// CeleryConfig is the merged config of a celery role group
+	CeleryConfig *airflowv1alpha1.CeleryConfigSpec
+	// Autoscaler is set when KEDA owns the replicas of the celery workers
+	Autoscaler *CeleryAutoscaler
+	// Flower is set for the flower role
+	Flower *airflowv1alpha1.FlowerSpec
+	// StandaloneDagProcessor is set for the schedulers of an airflow 2 cluster with dag processors,
+	// the scheduler then leaves DAG parsing to them.
+	StandaloneDagProcessor bool
 }
 
 // NewStatefulSetBuilder returns a new StatefulSetBuilder
@@ -128,11 +153,16 @@ func NewStatefulSetBuilder(
 	image *util.Image,
 	ports []corev1.ContainerPort,
 	overrides *commonsv1alpha1.OverridesSpec,
-	roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec,
+	config *airflowv1alpha1.ConfigSpec,
 	executor ExecutorType,
+	celery *CeleryBroker,
 	auth *Authentication,
 	options ...builder.Option,
 ) *StatefulSetBuilder {
+	var roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
+	if config != nil {
+		roleGroupConfig = config.RoleGroupConfigSpec
+	}
 	return &StatefulSetBuilder{
 		StatefulSet: *builder.NewStatefulSetBuilder(
 			client,
@@ -144,17 +174,69 @@ func NewStatefulSetBuilder(
 			options...,
 		),
 		ClusterConfig: clusterConfig,
+		Config:        config,
+		Ports:         ports,
 		Executor:      executor,
+		Celery:        celery,
+		Auth:          auth,
+	}
+}
+
+// getListenerClass returns the listener class exposing the role group, only webservers and flower are exposed
+func (b *StatefulSetBuilder) getListenerClass() constants.ListenerClass {
+	switch airflowv1alpha1.RoleName(b.RoleName) {
+	case airflowv1alpha1.WebserversRoleName:
+		if b.ClusterConfig == nil {
+			return ""
+		}
+		return b.ClusterConfig.ListenerClass
+	case airflowv1alpha1.FlowerRoleName:
+		return GetFlowerListenerClass(b.ClusterConfig, b.Flower)
+	default:
+		return ""
+	}
+}
+
+// getAuth returns the authentication of the webservers, other roles do not serve the UI
+func (b *StatefulSetBuilder) getAuth() *Authentication {
+	if b.RoleName != string(airflowv1alpha1.WebserversRoleName) {
+		return nil
+	}
+	return b.Auth
+}
+
+// getGitSyncs returns the git syncs of the roles reading the DAGs, flower only monitors celery
+// and runs no DAG code, so it gets none.
+func (b *StatefulSetBuilder) getGitSyncs() []*GitSync {
+	switch b.RoleName {
+	case string(airflowv1alpha1.SchedulersRoleName),
+		string(airflowv1alpha1.WebserversRoleName),
+		string(airflowv1alpha1.CeleryExecutorsRoleName),
+		string(airflowv1alpha1.TriggerersRoleName),
+		string(airflowv1alpha1.DagProcessorsRoleName):
+		return GetGitSyncs(b.ClusterConfig)
+	default:
+		return nil
 	}
 }
 
 func (b *StatefulSetBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
+	userVolumes, userVolumeMounts, err := GetUserVolumes(b.ClusterConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	cb, err := b.getMainContainer()
 	if err != nil {
 		return nil, err
 	}
+	cb.AddVolumeMounts(userVolumeMounts)
 	mc := b.getMetricContainer()
-	b.AddContainer(cb.Build())
+	mainContainer := cb.Build()
+	if b.RoleName == string(airflowv1alpha1.CeleryExecutorsRoleName) {
+		mainContainer.Lifecycle = getCeleryWorkerLifecycle(GetCeleryWorkerQueues(b.CeleryConfig))
+	}
+	b.AddContainer(mainContainer)
 	b.AddContainer(mc.Build())
 	b.AddVolumes([]corev1.Volume{
 		{
@@ -176,11 +258,65 @@ func (b *StatefulSetBuilder) Build(ctx context.Context) (ctrlclient.Object, erro
 		},
 	})
 
+	b.AddVolumes(userVolumes)
+
+	if auth := b.getAuth(); auth != nil {
+		b.AddVolumes(auth.GetVolumes())
+	}
+
+	if gitSyncs := b.getGitSyncs(); len(gitSyncs) > 0 {
+		for _, gitSync := range gitSyncs {
+			b.AddInitContainer(gitSync.GetInitContainer())
+			b.AddContainer(gitSync.GetSidecarContainer())
+		}
+		b.AddVolumes([]corev1.Volume{GetGitSyncVolume()})
+	}
+
+	if metadataDatabase := NewMetadataDatabase(b.ClusterConfig); metadataDatabase != nil {
+		b.AddVolumes(metadataDatabase.GetVolumes())
+	}
+
+	if volume := GetListenerVolume(b.getListenerClass(), b.Name); volume != nil {
+		b.AddVolume(volume)
+	}
+
+	if b.Executor == CeleryExecutor && b.Celery != nil {
+		b.AddVolumes(b.Celery.GetVolumes())
+	}
+
+	if b.Executor == KubernetesExecutor {
+		b.AddVolume(&corev1.Volume{
+			Name: KubernetesExecutorPodTemplateVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					DefaultMode:          &[]int32{420}[0],
+					LocalObjectReference: corev1.LocalObjectReference{Name: GetKubernetesExecutorResourceName(b.ClusterName)},
+					Items: []corev1.KeyToPath{
+						{Key: KubernetesExecutorPodTemplateFileName, Path: KubernetesExecutorPodTemplateFileName},
+					},
+				},
+			},
+		})
+	}
+
+	if b.Autoscaler != nil {
+		replicas, err := b.getAutoscaledReplicas(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b.SetReplicas(replicas)
+	}
+
 	obj, err := b.GetObject()
 	if err != nil {
 		return nil, err
 	}
 
+	if b.Executor == KubernetesExecutor {
+		// the scheduler launches task pods, the webserver reads their logs
+		obj.Spec.Template.Spec.ServiceAccountName = GetKubernetesExecutorResourceName(b.ClusterName)
+	}
+
 	if b.ClusterConfig != nil && b.ClusterConfig.VectorAggregatorConfigMapName != "" {
 		vector := builder.NewVector(b.Name, LogVolumeMountName, b.GetImage())
 		b.AddContainer(vector.GetContainer())
@@ -197,29 +333,30 @@ func (b *StatefulSetBuilder) getMainContainerArgs() (string, error) {
 	switch airflowv1alpha1.RoleName(b.RoleName) {
 	case airflowv1alpha1.WebserversRoleName:
 		mainCommand = "airflow webserver &"
+		if IsAirflow3(b.Image.ProductVersion) {
+			mainCommand = "airflow api-server &"
+		}
 	case airflowv1alpha1.SchedulersRoleName:
-		mainCommand = `
-airflow db init
-airflow db upgrade
-set +x	# disable xtrace
-airflow users create \
-	--username $` + EnvKeyAdminUserName + ` \
-	--firstname $` + ENVKeyAdminFirstName + ` \
-	--lastname $` + EnvKeyAdminLastName + ` \
-	--email $` + EnvKeyAdminEmail + ` \
-	--password $` + EnvKeyAdminPassword + ` \
-	--role "Admin"
-
-set -x 	# enable xtrace
-
-airflow scheduler &
-`
+		// the database is migrated by the migration job before the scheduler starts
+		mainCommand = "airflow scheduler &"
 	case airflowv1alpha1.CeleryExecutorsRoleName:
-		mainCommand = "airflow celery worker &"
+		mainCommand = "airflow celery worker" + getCeleryWorkerArgs(b.CeleryConfig) + " &"
+	case airflowv1alpha1.TriggerersRoleName:
+		mainCommand = "airflow triggerer &"
+	case airflowv1alpha1.DagProcessorsRoleName:
+		mainCommand = "airflow dag-processor &"
+	case airflowv1alpha1.FlowerRoleName:
+		mainCommand = "airflow celery flower &"
 	default:
 		return "", fmt.Errorf("unsupported role %s", b.RoleName)
 	}
 
+	if auth := b.getAuth(); auth != nil {
+		if commands := auth.GetCommands(); len(commands) > 0 {
+			mainCommand = strings.Join(commands, "\n") + "\n\n" + mainCommand
+		}
+	}
+
 	args := `
 mkdir -p ` + AppConfigPath + `
 mkdir -p ` + AirflowHome + `
@@ -238,24 +375,29 @@ mkdir -p ` + builder.VectorWatcherDir + ` && touch ` + builder.VectorShutdownFil
 	return util.IndentTab4Spaces(args), nil
 }
 
-func (b *StatefulSetBuilder) setMainContainerEnv() ([]corev1.EnvVar, error) {
-	credentialsName := b.ClusterConfig.Credentials
+// getAirflowEnvVars returns the environment shared by every airflow process of the cluster,
+// including the task pods launched by the kubernetes executor.
+func getAirflowEnvVars(
+	clusterName string,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+	executor ExecutorType,
+	celery *CeleryBroker,
+) ([]corev1.EnvVar, error) {
+	credentialsName := clusterConfig.Credentials
 	if credentialsName == "" {
 		return nil, fmt.Errorf("credentials secret name in cluster config is empty")
 	}
 
-	DagFloder := path.Join(constants.KubedoopRoot, "airflow", "dags")
+	gitSyncs := GetGitSyncs(clusterConfig)
+	DagFloder := GetDagsFolder(gitSyncs)
 
-	if len(b.ClusterConfig.DagsGitSync) > 0 {
-		dag := b.ClusterConfig.DagsGitSync[0]
-		if dag.GitFolder != "" {
-			DagFloder = path.Join(DagFloder, dag.GitFolder)
-		}
+	pythonPaths := []string{AppConfigPath}
+	if len(gitSyncs) == 0 {
+		pythonPaths = append(pythonPaths, DagFloder)
 	}
-
-	pythonPaths := []string{
-		AppConfigPath,
-		DagFloder,
+	for _, gitSync := range gitSyncs {
+		pythonPaths = append(pythonPaths, gitSync.GetDagFolder())
 	}
 
 	var envs = []corev1.EnvVar{
@@ -271,35 +413,50 @@ func (b *StatefulSetBuilder) setMainContainerEnv() ([]corev1.EnvVar, error) {
 			Name:  "AIRFLOW__LOGGING__LOGGING_CONFIG_CLASS",
 			Value: "log_config.LOGGING_CONFIG",
 		},
-		{
-			Name:  "AIRFLOW__METRICS__STATSD_ON",
-			Value: "True",
+	}
+
+	airflow3 := IsAirflow3(image.ProductVersion)
+	exposeConfigEnvName := "AIRFLOW__WEBSERVER__EXPOSE_CONFIG"
+	if airflow3 {
+		airflow3Envs, err := getAirflow3EnvVars(clusterName, credentialsName, gitSyncs)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, airflow3Envs...)
+		exposeConfigEnvName = "AIRFLOW__API__EXPOSE_CONFIG"
+	} else {
+		envs = append(envs,
+			corev1.EnvVar{
+				Name:  "AIRFLOW__API__AUTH_BACKENDS",
+				Value: "airflow.api.auth.backend.basic_auth",
+			},
+			getAppSecretKeyEnvVar("AIRFLOW__WEBSERVER__SECRET_KEY", credentialsName),
+		)
+	}
+
+	envs = append(envs,
+		corev1.EnvVar{
+			Name:  "AIRFLOW__CORE__LOAD_EXAMPLES",
+			Value: strconv.FormatBool(clusterConfig.LoadExamples),
 		},
-		{
-			Name:  "AIRFLOW__METRICS__STATSD_HOST",
-			Value: "0.0.0.0",
+		corev1.EnvVar{
+			Name:  exposeConfigEnvName,
+			Value: strconv.FormatBool(clusterConfig.ExposeConfig),
 		},
-		{
-			Name:  "AIRFLOW__METRICS__STATSD_PORT",
-			Value: "8125",
-		},
-		{
-			Name:  "AIRFLOW__API__AUTH_BACKENDS",
-			Value: "airflow.api.auth.backend.basic_auth",
+		corev1.EnvVar{
+			Name:  "AIRFLOW__CORE__EXECUTOR",
+			Value: GetExecutorName(executor),
 		},
+	)
 
-		{
-			Name: "AIRFLOW__WEBSERVER__SECRET_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "appSecretKey",
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: credentialsName,
-					},
-				},
-			},
-		},
-		{
+	if metadataDatabase := NewMetadataDatabase(clusterConfig); metadataDatabase != nil {
+		dbEnvs, err := metadataDatabase.GetEnvVars()
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, dbEnvs...)
+	} else {
+		envs = append(envs, corev1.EnvVar{
 			Name: "AIRFLOW__DATABASE__SQL_ALCHEMY_CONN",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
@@ -309,22 +466,16 @@ func (b *StatefulSetBuilder) setMainContainerEnv() ([]corev1.EnvVar, error) {
 					},
 				},
 			},
-		},
-
-		{
-			Name:  "AIRFLOW__CORE__LOAD_EXAMPLES",
-			Value: strconv.FormatBool(b.ClusterConfig.LoadExamples),
-		},
-		{
-			Name:  "AIRFLOW__WEBSERVER__EXPOSE_CONFIG",
-			Value: strconv.FormatBool(b.ClusterConfig.ExposeConfig),
-		},
-		{
-			Name:  "AIRFLOW__CORE__EXECUTOR",
-			Value: GetExecutorName(b.Executor),
-		},
+		})
 	}
-	if b.Executor == CeleryExecutor {
+
+	if executor == CeleryExecutor && celery != nil {
+		celeryEnvs, err := celery.GetEnvVars()
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, celeryEnvs...)
+	} else if executor == CeleryExecutor {
 		envs = append(envs,
 			corev1.EnvVar{
 				Name: "AIRFLOW__CELERY__RESULT_BACKEND",
@@ -350,57 +501,184 @@ func (b *StatefulSetBuilder) setMainContainerEnv() ([]corev1.EnvVar, error) {
 			},
 		)
 	}
-	// TODO: add support for KubernetesExecutor to pod template
-	// if b.Executor == KubernetesExecutor {
-	// 	envs = append(envs,
-	// 		corev1.EnvVar{
-	// 			Name:  "AIRFLOW__KUBERNETES_EXECUTOR__POD_TEMPLATE_FILE",
-	// 			Value: path.Join(KubernetesExecutorPodTemplatePath, KubernetesExecutorPodTemplateFileName),
-	// 		},
-	// 		corev1.EnvVar{
-	// 			Name:  "AIRFLOW__KUBERNETES_EXECUTOR__NAMESPACE",
-	// 			Value: b.GetObjectMeta().Namespace,
-	// 		},
-	// 		corev1.EnvVar{
-	// 			Name:  "AIRFLOW__CORE__EXECUTOR",
-	// 			Value: string(LocalExecutor),
-	// 		},
-	// 	)
-	// }
-
-	if b.RoleName == string(airflowv1alpha1.SchedulersRoleName) {
-		envKeyMapping := [][]string{
-			{EnvKeyAdminUserName, "adminUser.username"},
-			{ENVKeyAdminFirstName, "adminUser.firstname"},
-			{EnvKeyAdminLastName, "adminUser.lastname"},
-			{EnvKeyAdminEmail, "adminUser.email"},
-			{EnvKeyAdminPassword, "adminUser.password"},
+
+	return envs, nil
+}
+
+// getAirflow3EnvVars returns the settings airflow 3 moved or added. The FAB auth manager
+// keeps webserver_config.py, the authentication methods and the users command working,
+// the default simple auth manager supports none of them. Task runners talk to the
+// execution api of the api-server and authenticate with a JWT signed by every component,
+// so the JWT secret is shared through the credentials secret like the app secret key.
+func getAirflow3EnvVars(clusterName, credentialsName string, gitSyncs []*GitSync) ([]corev1.EnvVar, error) {
+	dagBundles, err := getDagBundles(gitSyncs)
+	if err != nil {
+		return nil, err
+	}
+
+	return []corev1.EnvVar{
+		{
+			Name:  "AIRFLOW__CORE__AUTH_MANAGER",
+			Value: "airflow.providers.fab.auth_manager.fab_auth_manager.FabAuthManager",
+		},
+		{
+			Name:  "AIRFLOW__FAB__AUTH_BACKENDS",
+			Value: "airflow.providers.fab.auth_manager.api.auth.backend.basic_auth",
+		},
+		getAppSecretKeyEnvVar("AIRFLOW__API__SECRET_KEY", credentialsName),
+		getAppSecretKeyEnvVar("AIRFLOW__API_AUTH__JWT_SECRET", credentialsName),
+		{
+			Name:  "AIRFLOW__CORE__EXECUTION_API_SERVER_URL",
+			Value: "http://" + GetWebserverServiceName(clusterName) + ":" + strconv.Itoa(WebserverPort) + "/execution/",
+		},
+		{
+			Name:  "AIRFLOW__DAG_PROCESSOR__DAG_BUNDLE_CONFIG_LIST",
+			Value: dagBundles,
+		},
+	}, nil
+}
+
+// getDagBundles returns the DAG bundles of airflow 3, every synced repo is a local
+// bundle, git-sync keeps them up to date. Without repos the DAGs folder is the only bundle.
+func getDagBundles(gitSyncs []*GitSync) (string, error) {
+	type dagBundle struct {
+		Name      string            `json:"name"`
+		Classpath string            `json:"classpath"`
+		Kwargs    map[string]string `json:"kwargs"`
+	}
+	const localDagBundle = "airflow.dag_processing.bundles.local.LocalDagBundle"
+
+	bundles := []dagBundle{{Name: "dags-folder", Classpath: localDagBundle, Kwargs: map[string]string{"path": DagsRoot}}}
+	if len(gitSyncs) > 0 {
+		bundles = make([]dagBundle, 0, len(gitSyncs))
+		for _, gitSync := range gitSyncs {
+			bundles = append(bundles, dagBundle{
+				Name:      gitSync.GetName(),
+				Classpath: localDagBundle,
+				Kwargs:    map[string]string{"path": gitSync.GetDagFolder()},
+			})
 		}
+	}
 
-		for _, mapping := range envKeyMapping {
-			envs = append(envs, corev1.EnvVar{
-				Name: mapping[0],
-				ValueFrom: &corev1.EnvVarSource{
-					SecretKeyRef: &corev1.SecretKeySelector{
-						Key: mapping[1],
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: credentialsName,
-						},
+	out, err := json.Marshal(bundles)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func getAppSecretKeyEnvVar(name, credentialsName string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key: "appSecretKey",
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: credentialsName,
+				},
+			},
+		},
+	}
+}
+
+// getAdminUserEnvVars returns the admin user read from the credentials secret
+func getAdminUserEnvVars(credentialsName string) []corev1.EnvVar {
+	envKeyMapping := [][]string{
+		{EnvKeyAdminUserName, "adminUser.username"},
+		{ENVKeyAdminFirstName, "adminUser.firstname"},
+		{EnvKeyAdminLastName, "adminUser.lastname"},
+		{EnvKeyAdminEmail, "adminUser.email"},
+		{EnvKeyAdminPassword, "adminUser.password"},
+	}
+
+	envs := make([]corev1.EnvVar, 0, len(envKeyMapping))
+	for _, mapping := range envKeyMapping {
+		envs = append(envs, corev1.EnvVar{
+			Name: mapping[0],
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: mapping[1],
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: credentialsName,
 					},
 				},
-			})
-		}
+			},
+		})
+	}
+	return envs
+}
+
+func (b *StatefulSetBuilder) setMainContainerEnv() ([]corev1.EnvVar, error) {
+	envs, err := getAirflowEnvVars(b.ClusterName, b.ClusterConfig, b.Image, b.Executor, b.Celery)
+	if err != nil {
+		return nil, err
+	}
+
+	envs = append(envs,
+		corev1.EnvVar{
+			Name:  "AIRFLOW__METRICS__STATSD_ON",
+			Value: "True",
+		},
+		corev1.EnvVar{
+			Name:  "AIRFLOW__METRICS__STATSD_HOST",
+			Value: "0.0.0.0",
+		},
+		corev1.EnvVar{
+			Name:  "AIRFLOW__METRICS__STATSD_PORT",
+			Value: "8125",
+		},
+	)
+
+	if b.RoleName == string(airflowv1alpha1.TriggerersRoleName) {
+		// pods of a StatefulSet without headless service have no resolvable hostname,
+		// so the triggerer registers its IP for the webserver to fetch logs from.
+		envs = append(envs,
+			corev1.EnvVar{
+				Name:  "AIRFLOW__LOGGING__TRIGGER_LOG_SERVER_PORT",
+				Value: strconv.Itoa(TriggererLogServerPort),
+			},
+			corev1.EnvVar{
+				Name:  "AIRFLOW__CORE__HOSTNAME_CALLABLE",
+				Value: "airflow.utils.net.get_host_ip_address",
+			},
+		)
 	}
 
-	if b.RoleGroupName == string(airflowv1alpha1.WebserversRoleName) && b.Auth != nil {
-		envs = append(envs, b.Auth.GetEnvVars()...)
+	// airflow 2 refuses to start the dag processor unless it is configured standalone
+	if (b.RoleName == string(airflowv1alpha1.DagProcessorsRoleName) && !IsAirflow3(b.Image.ProductVersion)) ||
+		(b.RoleName == string(airflowv1alpha1.SchedulersRoleName) && b.StandaloneDagProcessor) {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "AIRFLOW__SCHEDULER__STANDALONE_DAG_PROCESSOR",
+			Value: "True",
+		})
+	}
+
+	if b.Executor == KubernetesExecutor {
+		envs = append(envs,
+			corev1.EnvVar{
+				Name:  "AIRFLOW__KUBERNETES_EXECUTOR__POD_TEMPLATE_FILE",
+				Value: path.Join(KubernetesExecutorPodTemplatePath, KubernetesExecutorPodTemplateFileName),
+			},
+			corev1.EnvVar{
+				Name:  "AIRFLOW__KUBERNETES_EXECUTOR__NAMESPACE",
+				Value: b.Client.GetOwnerNamespace(),
+			},
+		)
+	}
+
+	if auth := b.getAuth(); auth != nil {
+		envs = append(envs, auth.GetEnvVars()...)
+	}
+
+	if b.RoleName == string(airflowv1alpha1.FlowerRoleName) {
+		envs = append(envs, GetFlowerEnvVars(b.Flower)...)
 	}
 
 	return envs, nil
 }
 
 func (b *StatefulSetBuilder) getMainContainerVolumeMount() []corev1.VolumeMount {
-	return []corev1.VolumeMount{
+	mounts := []corev1.VolumeMount{
 		{
 			Name:      ConfigVolumeMountName,
 			MountPath: constants.KubedoopConfigDirMount,
@@ -410,6 +688,35 @@ func (b *StatefulSetBuilder) getMainContainerVolumeMount() []corev1.VolumeMount
 			MountPath: constants.KubedoopLogDir,
 		},
 	}
+
+	if len(b.getGitSyncs()) > 0 {
+		mounts = append(mounts, GetGitSyncVolumeMount())
+	}
+
+	if metadataDatabase := NewMetadataDatabase(b.ClusterConfig); metadataDatabase != nil {
+		mounts = append(mounts, metadataDatabase.GetVolumeMounts()...)
+	}
+
+	if b.getListenerClass() != "" {
+		mounts = append(mounts, GetListenerVolumeMount())
+	}
+
+	if auth := b.getAuth(); auth != nil {
+		mounts = append(mounts, auth.GetVolumeMounts()...)
+	}
+
+	if b.Executor == CeleryExecutor && b.Celery != nil {
+		mounts = append(mounts, b.Celery.GetVolumeMounts()...)
+	}
+
+	if b.Executor == KubernetesExecutor {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      KubernetesExecutorPodTemplateVolumeName,
+			MountPath: KubernetesExecutorPodTemplatePath,
+		})
+	}
+
+	return mounts
 }
 
 func (b *StatefulSetBuilder) getMainContainer() (builder.ContainerBuilder, error) {
@@ -426,7 +733,15 @@ func (b *StatefulSetBuilder) getMainContainer() (builder.ContainerBuilder, error
 	}
 	container.AddEnvVars(envs)
 
+	// the metrics port is served by the metric container
+	for _, port := range b.Ports {
+		if port.Name != "metrics" {
+			container.AddPorts([]corev1.ContainerPort{port})
+		}
+	}
+
 	container.AddVolumeMounts(b.getMainContainerVolumeMount())
+	b.setMainContainerProbes(container)
 
 	return container, nil
 }
@@ -442,5 +757,6 @@ prepare_signal_handlers
 wait_for_termination $!`
 
 	container.SetArgs([]string{util.IndentTab4Spaces(args)})
+	setMetricContainerProbes(container)
 	return container
 }
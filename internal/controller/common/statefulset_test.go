@@ -0,0 +1,358 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commons
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+var _ = Describe("StatefulSetBuilder", func() {
+	var (
+		ctx           = context.Background()
+		c             *client.Client
+		clusterConfig *airflowv1alpha1.ClusterConfigSpec
+		auth          *Authentication
+		config        *airflowv1alpha1.ConfigSpec
+		celeryConfig  *airflowv1alpha1.CeleryConfigSpec
+		flower        *airflowv1alpha1.FlowerSpec
+		standalone    bool
+	)
+
+	oidcAuthentication := airflowv1alpha1.AuthenticationSpec{
+		AuthenticationClass: "oidc",
+		Oidc:                &authv1alpha1.OidcSpec{ClientCredentialsSecret: "oidc-client"},
+	}
+	ldapAuthentication := airflowv1alpha1.AuthenticationSpec{AuthenticationClass: "ldap"}
+
+	authenticate := func(authentication airflowv1alpha1.AuthenticationSpec) {
+		clusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{authentication}
+		var err error
+		auth, err = NewAuthentication(ctx, c, clusterConfig.Authentication)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		cluster := &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+		}
+		objects := []ctrlclient.Object{
+			&authv1alpha1.AuthenticationClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "oidc"},
+				Spec: authv1alpha1.AuthenticationClassSpec{
+					AuthenticationProvider: &authv1alpha1.AuthenticationProvider{
+						OIDC: &authv1alpha1.OIDCProvider{Hostname: "keycloak.example.com", ProviderHint: "keycloak"},
+					},
+				},
+			},
+			&authv1alpha1.AuthenticationClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "ldap"},
+				Spec: authv1alpha1.AuthenticationClassSpec{
+					AuthenticationProvider: &authv1alpha1.AuthenticationProvider{
+						LDAP: &authv1alpha1.LDAPProvider{
+							Hostname:        "ldap.example.com",
+							BindCredentials: &commonsv1alpha1.Credentials{SecretClass: "ldap-bind"},
+						},
+					},
+				},
+			},
+		}
+		c = client.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(), cluster)
+
+		clusterConfig = &airflowv1alpha1.ClusterConfigSpec{Credentials: "airflow-credentials"}
+
+		config = nil
+		celeryConfig = nil
+		flower = nil
+		standalone = false
+
+		authenticate(oidcAuthentication)
+	})
+
+	build := func(roleName airflowv1alpha1.RoleName, roleGroupName string) *corev1.PodSpec {
+		name := "airflow-" + string(roleName) + "-" + roleGroupName
+		b := NewStatefulSetBuilder(
+			c,
+			name,
+			clusterConfig,
+			ptr.To[int32](1),
+			&util.Image{Repo: "quay.io/zncdatadev", ProductName: "airflow", ProductVersion: "2.10.2", KubedoopVersion: "0.0.0-dev"},
+			nil,
+			nil,
+			config,
+			LocalExecutor,
+			nil,
+			auth,
+			func(o *builder.Options) {
+				o.ClusterName = "airflow"
+				o.RoleName = string(roleName)
+				o.RoleGroupName = roleGroupName
+			},
+		)
+		b.CeleryConfig = celeryConfig
+		b.Flower = flower
+		b.StandaloneDagProcessor = standalone
+		obj, err := b.Build(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		return &obj.(*appsv1.StatefulSet).Spec.Template.Spec
+	}
+
+	mainContainer := func(spec *corev1.PodSpec, name string) *corev1.Container {
+		for i := range spec.Containers {
+			if spec.Containers[i].Name == name {
+				return &spec.Containers[i]
+			}
+		}
+		Fail("container " + name + " not found")
+		return nil
+	}
+
+	envNames := func(container *corev1.Container) []string {
+		names := make([]string, 0, len(container.Env))
+		for _, env := range container.Env {
+			names = append(names, env.Name)
+		}
+		return names
+	}
+
+	It("attaches the OIDC authentication to every webserver role group", func() {
+		for _, roleGroupName := range []string{"default", "internal"} {
+			spec := build(airflowv1alpha1.WebserversRoleName, roleGroupName)
+			container := mainContainer(spec, string(airflowv1alpha1.WebserversRoleName))
+
+			Expect(envNames(container)).To(ContainElements("OIDC_CLIENT_ID", "OIDC_CLIENT_SECRET"))
+		}
+	})
+
+	It("attaches the LDAP authentication to every webserver role group", func() {
+		authenticate(ldapAuthentication)
+		for _, roleGroupName := range []string{"default", "internal"} {
+			spec := build(airflowv1alpha1.WebserversRoleName, roleGroupName)
+			container := mainContainer(spec, string(airflowv1alpha1.WebserversRoleName))
+
+			Expect(envNames(container)).NotTo(ContainElement("OIDC_CLIENT_ID"))
+			Expect(spec.Volumes).To(ContainElement(HaveField("Name", "ldap-ldap-bind")))
+			Expect(container.VolumeMounts).To(ContainElement(HaveField("Name", "ldap-ldap-bind")))
+		}
+	})
+
+	It("does not attach the OIDC authentication to other roles", func() {
+		spec := build(airflowv1alpha1.SchedulersRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+
+		Expect(envNames(container)).NotTo(ContainElement("OIDC_CLIENT_ID"))
+	})
+
+	It("does not attach the LDAP authentication to other roles", func() {
+		authenticate(ldapAuthentication)
+		spec := build(airflowv1alpha1.SchedulersRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+
+		Expect(spec.Volumes).NotTo(ContainElement(HaveField("Name", "ldap-ldap-bind")))
+		Expect(container.VolumeMounts).NotTo(ContainElement(HaveField("Name", "ldap-ldap-bind")))
+	})
+
+	It("probes the webserver health endpoint", func() {
+		spec := build(airflowv1alpha1.WebserversRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.WebserversRoleName))
+
+		for _, probe := range []*corev1.Probe{container.StartupProbe, container.LivenessProbe, container.ReadinessProbe} {
+			Expect(probe).NotTo(BeNil())
+			Expect(probe.HTTPGet).NotTo(BeNil())
+			Expect(probe.HTTPGet.Path).To(Equal("/health"))
+			Expect(probe.HTTPGet.Port.IntValue()).To(Equal(WebserverPort))
+		}
+
+		metric := mainContainer(spec, "metric")
+		Expect(metric.ReadinessProbe.TCPSocket.Port.IntValue()).To(Equal(MetricsPort))
+	})
+
+	It("checks the scheduler job and applies the role group thresholds", func() {
+		config = &airflowv1alpha1.ConfigSpec{
+			Probes: &airflowv1alpha1.ProbesSpec{
+				Liveness: &airflowv1alpha1.ProbeSpec{PeriodSeconds: ptr.To[int32](120), FailureThreshold: ptr.To[int32](10)},
+			},
+		}
+		spec := build(airflowv1alpha1.SchedulersRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+
+		Expect(container.LivenessProbe.Exec.Command).To(ContainElement(ContainSubstring("airflow jobs check --job-type SchedulerJob --local")))
+		Expect(container.LivenessProbe.PeriodSeconds).To(Equal(int32(120)))
+		Expect(container.LivenessProbe.FailureThreshold).To(Equal(int32(10)))
+		Expect(container.LivenessProbe.TimeoutSeconds).To(Equal(execLivenessProbe.TimeoutSeconds))
+		Expect(container.ReadinessProbe.PeriodSeconds).To(Equal(execReadinessProbe.PeriodSeconds))
+	})
+
+	It("runs the airflow 2 dag processor standalone", func() {
+		spec := build(airflowv1alpha1.DagProcessorsRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.DagProcessorsRoleName))
+
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{
+			Name:  "AIRFLOW__SCHEDULER__STANDALONE_DAG_PROCESSOR",
+			Value: "True",
+		}))
+	})
+
+	It("leaves DAG parsing of the airflow 2 scheduler to the dag processors", func() {
+		spec := build(airflowv1alpha1.SchedulersRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+		Expect(envNames(container)).NotTo(ContainElement("AIRFLOW__SCHEDULER__STANDALONE_DAG_PROCESSOR"))
+
+		standalone = true
+		spec = build(airflowv1alpha1.SchedulersRoleName, "default")
+		container = mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{
+			Name:  "AIRFLOW__SCHEDULER__STANDALONE_DAG_PROCESSOR",
+			Value: "True",
+		}))
+	})
+
+	It("pings the local celery worker", func() {
+		spec := build(airflowv1alpha1.CeleryExecutorsRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.CeleryExecutorsRoleName))
+
+		Expect(container.ReadinessProbe.Exec.Command).To(ContainElement(ContainSubstring("inspect ping --destination celery@${HOSTNAME}")))
+	})
+
+	It("starts the celery worker on the queues of the role group", func() {
+		celeryConfig = &airflowv1alpha1.CeleryConfigSpec{
+			Queues:      []string{"gpu", "heavy-etl"},
+			Concurrency: ptr.To[int32](4),
+		}
+		spec := build(airflowv1alpha1.CeleryExecutorsRoleName, "ml")
+		container := mainContainer(spec, string(airflowv1alpha1.CeleryExecutorsRoleName))
+
+		Expect(container.Args).To(ContainElement(ContainSubstring("airflow celery worker --queues gpu,heavy-etl --concurrency 4 &")))
+		Expect(container.Lifecycle.PreStop.Exec.Command).To(ContainElement(And(
+			ContainSubstring("for queue in gpu heavy-etl;"),
+			ContainSubstring("control cancel_consumer"),
+		)))
+	})
+
+	It("applies the graceful shutdown timeout to the termination grace period", func() {
+		config = &airflowv1alpha1.ConfigSpec{
+			RoleGroupConfigSpec: &commonsv1alpha1.RoleGroupConfigSpec{GracefulShutdownTimeout: "10m"},
+		}
+		spec := build(airflowv1alpha1.CeleryExecutorsRoleName, "default")
+
+		Expect(spec.TerminationGracePeriodSeconds).To(Equal(ptr.To[int64](600)))
+		container := mainContainer(spec, string(airflowv1alpha1.CeleryExecutorsRoleName))
+		Expect(container.Lifecycle.PreStop.Exec.Command).To(ContainElement(ContainSubstring("for queue in default;")))
+	})
+
+	It("defaults the graceful shutdown timeout of the role without the webhook", func() {
+		config = DefaultConfig(airflowv1alpha1.CeleryExecutorsRoleName, nil)
+		spec := build(airflowv1alpha1.CeleryExecutorsRoleName, "default")
+		Expect(spec.TerminationGracePeriodSeconds).To(Equal(ptr.To[int64](600)))
+
+		userConfig := &airflowv1alpha1.ConfigSpec{
+			RoleGroupConfigSpec: &commonsv1alpha1.RoleGroupConfigSpec{GracefulShutdownTimeout: "1h"},
+		}
+		config = DefaultConfig(airflowv1alpha1.FlowerRoleName, userConfig)
+		Expect(config.GracefulShutdownTimeout).To(Equal("1h"))
+		Expect(userConfig).NotTo(BeIdenticalTo(config))
+	})
+
+	It("defaults the resources and logging of the role without the webhook", func() {
+		config = DefaultConfig(airflowv1alpha1.SchedulersRoleName, nil)
+		spec := build(airflowv1alpha1.SchedulersRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.SchedulersRoleName))
+
+		Expect(container.Resources.Requests.Cpu().String()).To(Equal("500m"))
+		Expect(container.Resources.Limits.Cpu().String()).To(Equal("1"))
+		Expect(container.Resources.Limits.Memory().String()).To(Equal("1Gi"))
+
+		logging := config.Logging.Containers[string(airflowv1alpha1.SchedulersRoleName)]
+		Expect(logging.Console.Level).To(Equal(DefaultLogLevel))
+		Expect(logging.Loggers).To(HaveKey("root"))
+	})
+
+	It("lets celery autoscale the worker pool", func() {
+		celeryConfig = &airflowv1alpha1.CeleryConfigSpec{
+			Concurrency: ptr.To[int32](4),
+			Autoscale:   &airflowv1alpha1.CeleryWorkerAutoscaleSpec{Min: 2, Max: 8},
+		}
+		spec := build(airflowv1alpha1.CeleryExecutorsRoleName, "io")
+		container := mainContainer(spec, string(airflowv1alpha1.CeleryExecutorsRoleName))
+
+		Expect(container.Args).To(ContainElement(ContainSubstring("airflow celery worker --autoscale 8,2 &")))
+	})
+
+	It("syncs the DAGs into every role running DAG code but flower", func() {
+		clusterConfig.DagsGitSync = []airflowv1alpha1.DagsGitSyncSpec{{Repo: "https://github.com/example/dags"}}
+		for _, roleName := range []airflowv1alpha1.RoleName{
+			airflowv1alpha1.SchedulersRoleName,
+			airflowv1alpha1.WebserversRoleName,
+			airflowv1alpha1.CeleryExecutorsRoleName,
+			airflowv1alpha1.TriggerersRoleName,
+			airflowv1alpha1.DagProcessorsRoleName,
+		} {
+			spec := build(roleName, "default")
+			Expect(spec.InitContainers).To(ContainElement(HaveField("Name", HavePrefix(GitSyncInitContainerName))), string(roleName))
+			Expect(spec.Containers).To(ContainElement(HaveField("Name", HavePrefix(GitSyncSidecarContainerName))), string(roleName))
+			Expect(mainContainer(spec, string(roleName)).VolumeMounts).To(ContainElement(HaveField("Name", GitSyncVolumeName)))
+		}
+
+		flower = &airflowv1alpha1.FlowerSpec{CredentialsSecret: "flower-credentials"}
+		spec := build(airflowv1alpha1.FlowerRoleName, "default")
+		Expect(spec.InitContainers).NotTo(ContainElement(HaveField("Name", HavePrefix(GitSyncInitContainerName))))
+		Expect(spec.Containers).NotTo(ContainElement(HaveField("Name", HavePrefix(GitSyncSidecarContainerName))))
+		Expect(spec.Volumes).NotTo(ContainElement(HaveField("Name", GitSyncVolumeName)))
+		Expect(mainContainer(spec, string(airflowv1alpha1.FlowerRoleName)).VolumeMounts).NotTo(
+			ContainElement(HaveField("Name", GitSyncVolumeName)),
+		)
+	})
+
+	It("runs flower behind basic auth on its listener", func() {
+		clusterConfig.ListenerClass = "cluster-internal"
+		flower = &airflowv1alpha1.FlowerSpec{CredentialsSecret: "flower-credentials", ListenerClass: "external-unstable"}
+		spec := build(airflowv1alpha1.FlowerRoleName, "default")
+		container := mainContainer(spec, string(airflowv1alpha1.FlowerRoleName))
+
+		Expect(container.Args).To(ContainElement(ContainSubstring("airflow celery flower &")))
+		Expect(envNames(container)).To(ContainElements("FLOWER_USERNAME", "FLOWER_PASSWORD"))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{
+			Name:  "AIRFLOW__CELERY__FLOWER_BASIC_AUTH",
+			Value: "$(FLOWER_USERNAME):$(FLOWER_PASSWORD)",
+		}))
+		Expect(envNames(container)).NotTo(ContainElement("OIDC_CLIENT_ID"))
+		Expect(container.ReadinessProbe.TCPSocket.Port.IntValue()).To(Equal(FlowerPort))
+
+		Expect(spec.Volumes).To(ContainElement(HaveField("Name", ListenerVolumeName)))
+		for _, volume := range spec.Volumes {
+			if volume.Name == ListenerVolumeName {
+				Expect(*volume.Ephemeral.VolumeClaimTemplate.Spec.StorageClassName).To(Equal("listeners.kubedoop.dev"))
+				Expect(volume.Ephemeral.VolumeClaimTemplate.Annotations).To(ContainElement("external-unstable"))
+			}
+		}
+	})
+})
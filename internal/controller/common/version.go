@@ -0,0 +1,53 @@
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AirflowVersion is the major version of airflow, commands, env vars and config
+// files are rendered for the major version of the product version.
+type AirflowVersion int
+
+const (
+	Airflow2 AirflowVersion = 2
+	Airflow3 AirflowVersion = 3
+)
+
+// GetAirflowVersion returns the major version of a product version, e.g. 3 for 3.0.2
+func GetAirflowVersion(productVersion string) (AirflowVersion, error) {
+	major, _, _ := strings.Cut(productVersion, ".")
+	v, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("invalid airflow version %q: %w", productVersion, err)
+	}
+	switch version := AirflowVersion(v); version {
+	case Airflow2, Airflow3:
+		return version, nil
+	default:
+		return 0, fmt.Errorf("unsupported airflow version %q, supported major versions are 2 and 3", productVersion)
+	}
+}
+
+// IsAirflow3 reports whether the product version is airflow 3,
+// a version that can not be parsed is rendered as airflow 2.
+func IsAirflow3(productVersion string) bool {
+	version, err := GetAirflowVersion(productVersion)
+	return err == nil && version == Airflow3
+}
+
+// HasDbMigrate reports whether the product version provides airflow db migrate, which replaced
+// airflow db upgrade in airflow 2.7, a version that can not be parsed is assumed to provide it.
+func HasDbMigrate(productVersion string) bool {
+	version, err := GetAirflowVersion(productVersion)
+	if err != nil || version != Airflow2 {
+		return true
+	}
+	parts := strings.SplitN(productVersion, ".", 3)
+	if len(parts) < 2 {
+		return true
+	}
+	minor, err := strconv.Atoi(parts[1])
+	return err != nil || minor >= 7
+}
@@ -0,0 +1,130 @@
+package commons
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+// DecodeVolume decodes an item of ClusterConfigSpec.Volumes, unknown fields are
+// rejected so a misspelled volume source is not silently dropped.
+func DecodeVolume(raw k8sruntime.RawExtension) (*corev1.Volume, error) {
+	volume := &corev1.Volume{}
+	if err := decodeStrict(raw, volume); err != nil {
+		return nil, err
+	}
+	if volume.Name == "" {
+		return nil, fmt.Errorf("volume name is empty")
+	}
+	return volume, nil
+}
+
+// DecodeVolumeMount decodes an item of ClusterConfigSpec.VolumeMounts
+func DecodeVolumeMount(raw k8sruntime.RawExtension) (*corev1.VolumeMount, error) {
+	mount := &corev1.VolumeMount{}
+	if err := decodeStrict(raw, mount); err != nil {
+		return nil, err
+	}
+	if mount.Name == "" || mount.MountPath == "" {
+		return nil, fmt.Errorf("volume mount requires name and mountPath")
+	}
+	return mount, nil
+}
+
+func decodeStrict(raw k8sruntime.RawExtension, obj any) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw.Raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(obj)
+}
+
+// reservedVolumeNames are the volumes the operator adds to the pods
+var reservedVolumeNames = []string{
+	ConfigVolumeMountName,
+	LogVolumeMountName,
+	ListenerVolumeName,
+	GitSyncVolumeName,
+	MetadataDatabaseVolumeName,
+	CeleryBrokerVolumeName,
+	CeleryResultBackendVolumeName,
+	KubernetesExecutorPodTemplateVolumeName,
+}
+
+// reservedVolumeNamePrefixes prefix the volumes the authentication providers add to the pods
+var reservedVolumeNamePrefixes = []string{LdapVolumeNamePrefix, OidcCAVolumeNamePrefix}
+
+func isReservedVolumeName(name string) bool {
+	if slices.Contains(reservedVolumeNames, name) {
+		return true
+	}
+	for _, prefix := range reservedVolumeNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserVolumes returns the volumes and mounts of the cluster config, which are added
+// to the pods and main container of every role.
+func GetUserVolumes(clusterConfig *airflowv1alpha1.ClusterConfigSpec) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	volumes, mounts, errs := DecodeUserVolumes(clusterConfig, field.NewPath("spec", "clusterConfig"))
+	if len(errs) > 0 {
+		return nil, nil, errs.ToAggregate()
+	}
+	return volumes, mounts, nil
+}
+
+// DecodeUserVolumes decodes the volumes and mounts of the cluster config and returns the errors
+// of the invalid ones. Volume names must be unique and not collide with the volumes of the
+// operator, and every mount must reference a declared volume.
+func DecodeUserVolumes(
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	path *field.Path,
+) ([]corev1.Volume, []corev1.VolumeMount, field.ErrorList) {
+	if clusterConfig == nil {
+		return nil, nil, nil
+	}
+	var allErrs field.ErrorList
+
+	volumes := make([]corev1.Volume, 0, len(clusterConfig.Volumes))
+	names := make(map[string]bool)
+	for i, raw := range clusterConfig.Volumes {
+		volumePath := path.Child("volumes").Index(i)
+		volume, err := DecodeVolume(raw)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(volumePath, string(raw.Raw), err.Error()))
+			continue
+		}
+		if names[volume.Name] {
+			allErrs = append(allErrs, field.Duplicate(volumePath.Child("name"), volume.Name))
+		} else if isReservedVolumeName(volume.Name) {
+			allErrs = append(allErrs, field.Invalid(volumePath.Child("name"), volume.Name, "the name is used by a volume of the operator"))
+		}
+		volumes = append(volumes, *volume)
+		names[volume.Name] = true
+	}
+
+	mounts := make([]corev1.VolumeMount, 0, len(clusterConfig.VolumeMounts))
+	for i, raw := range clusterConfig.VolumeMounts {
+		mountPath := path.Child("volumeMounts").Index(i)
+		mount, err := DecodeVolumeMount(raw)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(mountPath, string(raw.Raw), err.Error()))
+			continue
+		}
+		if !names[mount.Name] {
+			allErrs = append(allErrs, field.NotFound(mountPath.Child("name"), mount.Name))
+		}
+		mounts = append(mounts, *mount)
+	}
+
+	return volumes, mounts, allErrs
+}
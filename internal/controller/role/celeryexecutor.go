@@ -54,7 +54,7 @@ func (r *CeleryExecutorsReconciler) RegisterResources(ctx context.Context) error
 			RoleGroupName: name,
 		}
 
-		reconcilers, err := r.RegisterResourceWithRoleGroup(ctx, info, roleGroup.Replicas, mergedRoleGroupConfig, mergedOverrides)
+		reconcilers, err := r.RegisterResourceWithRoleGroup(ctx, info, roleGroup.Replicas, roleGroup.Autoscaling, mergedRoleGroupConfig, mergedOverrides)
 
 		if err != nil {
 			return err
@@ -71,18 +71,21 @@ func (r *CeleryExecutorsReconciler) RegisterResourceWithRoleGroup(
 	ctx context.Context,
 	info reconciler.RoleGroupInfo,
 	replicas *int32,
-	config *airflowv1alpha1.ConfigSpec,
+	autoscaling *airflowv1alpha1.CeleryAutoscalingSpec,
+	celeryConfig *airflowv1alpha1.CeleryConfigSpec,
 	overrides *commonsv1alpha1.OverridesSpec,
 ) ([]reconciler.Reconciler, error) {
 
+	var config *airflowv1alpha1.ConfigSpec
+	if celeryConfig != nil {
+		config = &celeryConfig.ConfigSpec
+	}
+	config = common.DefaultConfig(airflowv1alpha1.CeleryExecutorsRoleName, config)
+
 	var auth *common.Authentication
 	var err error
 	executorType := common.CeleryExecutor
-
-	var commonsRoleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
-	if config != nil {
-		commonsRoleGroupConfig = config.RoleGroupConfigSpec
-	}
+	celery := common.NewCeleryBroker(r.ClusterConfig, r.Spec)
 
 	if len(r.ClusterConfig.Authentication) > 0 {
 		auth, err = common.NewAuthentication(ctx, r.Client, r.ClusterConfig.Authentication)
@@ -106,28 +109,44 @@ func (r *CeleryExecutorsReconciler) RegisterResourceWithRoleGroup(
 		config,
 		info,
 		auth,
+		celery,
 		options,
 	)
 
-	deploymentReconciler, err := common.NewStatefulSetReconciler(
+	statefulSetBuilder := common.NewStatefulSetBuilder(
 		r.Client,
-		info,
+		info.GetFullName(),
 		r.ClusterConfig,
-		make([]corev1.ContainerPort, 0),
-		r.Image,
 		replicas,
-		r.ClusterStopped(),
+		r.Image,
+		make([]corev1.ContainerPort, 0),
 		overrides,
-		commonsRoleGroupConfig,
+		config,
 		executorType,
+		celery,
 		auth,
 		options,
 	)
-	if err != nil {
-		return nil, err
+	statefulSetBuilder.CeleryConfig = celeryConfig
+
+	var autoscalingReconcilers []reconciler.Reconciler
+	if autoscaling != nil {
+		autoscaler := common.NewCeleryAutoscaler(r.ClusterConfig, r.Spec, autoscaling, common.GetCeleryWorkerQueues(celeryConfig))
+		// while stopped the replicas are scaled to zero, KEDA is paused meanwhile
+		if !r.ClusterStopped() {
+			statefulSetBuilder.Autoscaler = autoscaler
+		}
+		autoscalingReconcilers = common.NewCeleryAutoscalingReconcilers(r.Client, info, autoscaler, r.ClusterStopped(), options)
+	} else {
+		// KEDA keeps scaling the workers until the resources of a removed autoscaling are deleted
+		autoscalingReconcilers = common.NewCeleryAutoscalingCleanupReconcilers(r.Client, info)
 	}
 
+	deploymentReconciler := reconciler.NewStatefulSet(r.Client, statefulSetBuilder, r.ClusterStopped())
+
 	metricsSvc := common.GetServiceReconciler(r, info, ports)
 
-	return []reconciler.Reconciler{configmapReconciler, deploymentReconciler, metricsSvc}, nil
+	reconcilers := []reconciler.Reconciler{configmapReconciler, deploymentReconciler}
+	reconcilers = append(reconcilers, autoscalingReconcilers...)
+	return append(reconcilers, metricsSvc), nil
 }
@@ -0,0 +1,130 @@
+package role
+
+import (
+	"context"
+
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+var _ reconciler.RoleReconciler = &FlowerReconciler{}
+
+// FlowerReconciler runs celery flower, it reads the state of the workers from the celery broker
+type FlowerReconciler struct {
+	reconciler.BaseRoleReconciler[*airflowv1alpha1.FlowerSpec]
+	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
+	Image         *util.Image
+	Celery        *common.CeleryBroker
+}
+
+func NewFlowerReconciler(
+	client *client.Client,
+	clusterStopped bool,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleInfo reconciler.RoleInfo,
+	image *util.Image,
+	celery *common.CeleryBroker,
+	spec *airflowv1alpha1.FlowerSpec,
+) *FlowerReconciler {
+	return &FlowerReconciler{
+		BaseRoleReconciler: *reconciler.NewBaseRoleReconciler(client, clusterStopped, roleInfo, spec),
+		ClusterConfig:      clusterConfig,
+		Image:              image,
+		Celery:             celery,
+	}
+}
+
+func (r *FlowerReconciler) RegisterResources(ctx context.Context) error {
+	for name, roleGroup := range r.Spec.RoleGroups {
+		mergedRoleGroupConfig, err := util.MergeObject(r.Spec.Config, roleGroup.Config)
+		if err != nil {
+			return err
+		}
+		mergedRoleGroupConfig = common.DefaultConfig(airflowv1alpha1.FlowerRoleName, mergedRoleGroupConfig)
+
+		mergedOverrides, err := util.MergeObject(r.Spec.OverridesSpec, roleGroup.OverridesSpec)
+		if err != nil {
+			return err
+		}
+
+		info := reconciler.RoleGroupInfo{
+			RoleInfo:      r.RoleInfo,
+			RoleGroupName: name,
+		}
+
+		reconcilers, err := r.RegisterResourceWithRoleGroup(ctx, info, roleGroup.Replicas, mergedRoleGroupConfig, mergedOverrides)
+
+		if err != nil {
+			return err
+		}
+
+		for _, reconciler := range reconcilers {
+			r.AddResource(reconciler)
+		}
+	}
+	return nil
+}
+
+func (r *FlowerReconciler) RegisterResourceWithRoleGroup(
+	_ context.Context,
+	info reconciler.RoleGroupInfo,
+	replicas *int32,
+	config *airflowv1alpha1.ConfigSpec,
+	overrides *commonsv1alpha1.OverridesSpec,
+) ([]reconciler.Reconciler, error) {
+
+	options := func(o *builder.Options) {
+		o.ClusterName = info.GetClusterName()
+		o.RoleName = info.GetRoleName()
+		o.RoleGroupName = info.GetGroupName()
+
+		o.Labels = info.GetLabels()
+		o.Annotations = info.GetAnnotations()
+	}
+
+	configmapReconciler := common.NewConfigReconciler(
+		r.Client,
+		r.ClusterConfig,
+		config,
+		info,
+		nil,
+		r.Celery,
+		options,
+	)
+
+	statefulSetBuilder := common.NewStatefulSetBuilder(
+		r.Client,
+		info.GetFullName(),
+		r.ClusterConfig,
+		replicas,
+		r.Image,
+		flowerPorts,
+		overrides,
+		config,
+		common.CeleryExecutor,
+		r.Celery,
+		nil,
+		options,
+	)
+	statefulSetBuilder.Flower = r.Spec
+
+	deploymentReconciler := reconciler.NewStatefulSet(r.Client, statefulSetBuilder, r.ClusterStopped())
+
+	svc := reconciler.NewServiceReconciler(
+		r.Client,
+		info.GetFullName(),
+		flowerPorts,
+		func(o *builder.ServiceBuilderOptions) {
+			o.Labels = info.GetLabels()
+			o.Annotations = info.GetAnnotations()
+		},
+	)
+
+	return []reconciler.Reconciler{configmapReconciler, deploymentReconciler, svc}, nil
+}
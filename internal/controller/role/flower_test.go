@@ -0,0 +1,88 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package role
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+var _ = Describe("FlowerReconciler", func() {
+	ctx := context.Background()
+
+	It("selects only the flower pods of the role group", func() {
+		cluster := &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+		}
+		c := client.NewClient(fake.NewClientBuilder().WithScheme(scheme).Build(), cluster)
+		roleInfo := reconciler.RoleInfo{
+			ClusterInfo: reconciler.ClusterInfo{
+				GVK: &metav1.GroupVersionKind{
+					Group:   airflowv1alpha1.GroupVersion.Group,
+					Version: airflowv1alpha1.GroupVersion.Version,
+					Kind:    "AirflowCluster",
+				},
+				ClusterName: "airflow",
+			},
+			RoleName: string(airflowv1alpha1.FlowerRoleName),
+		}
+		r := NewFlowerReconciler(
+			c,
+			false,
+			&airflowv1alpha1.ClusterConfigSpec{Credentials: "airflow-credentials"},
+			roleInfo,
+			&util.Image{Repo: "quay.io/zncdatadev", ProductName: "airflow", ProductVersion: "2.10.2", KubedoopVersion: "0.0.0-dev"},
+			nil,
+			&airflowv1alpha1.FlowerSpec{CredentialsSecret: "flower-credentials"},
+		)
+
+		reconcilers, err := r.RegisterResourceWithRoleGroup(
+			ctx,
+			reconciler.RoleGroupInfo{RoleInfo: roleInfo, RoleGroupName: "default"},
+			ptr.To[int32](1),
+			nil,
+			nil,
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		var svc *corev1.Service
+		for _, rec := range reconcilers {
+			if s, ok := rec.(*reconciler.Service); ok {
+				obj, err := s.GetBuilder().Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				svc = obj.(*corev1.Service)
+			}
+		}
+		Expect(svc).NotTo(BeNil())
+		Expect(svc.Spec.Selector).To(HaveKeyWithValue(constants.LabelKubernetesComponent, string(airflowv1alpha1.FlowerRoleName)))
+		Expect(svc.Spec.Selector).To(HaveKeyWithValue(constants.LabelKubernetesRoleGroup, "default"))
+		Expect(svc.Spec.Ports).To(ConsistOf(HaveField("Port", int32(common.FlowerPort))))
+	})
+})
@@ -0,0 +1,110 @@
+package role
+
+import (
+	"context"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+var _ reconciler.RoleReconciler = &KubernetesExecutorsReconciler{}
+
+// KubernetesExecutorsReconciler has no role groups, it only renders the pod template
+// and the rbac resources the scheduler uses to launch task pods.
+type KubernetesExecutorsReconciler struct {
+	reconciler.BaseRoleReconciler[*airflowv1alpha1.KubernetesExecutorsSpec]
+	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
+	Image         *util.Image
+}
+
+func NewKubernetesExecutorsReconciler(
+	client *client.Client,
+	clusterStopped bool,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	roleInfo reconciler.RoleInfo,
+	image *util.Image,
+	spec *airflowv1alpha1.KubernetesExecutorsSpec,
+) *KubernetesExecutorsReconciler {
+	return &KubernetesExecutorsReconciler{
+		BaseRoleReconciler: *reconciler.NewBaseRoleReconciler(client, clusterStopped, roleInfo, spec),
+		ClusterConfig:      clusterConfig,
+		Image:              image,
+	}
+}
+
+func (r *KubernetesExecutorsReconciler) RegisterResources(ctx context.Context) error {
+	mergedConfig, err := util.MergeObject(r.Spec.Config, &airflowv1alpha1.ConfigSpec{RoleGroupConfigSpec: r.Spec.RoleGroupConfigSpec})
+	if err != nil {
+		return err
+	}
+	mergedConfig = common.DefaultConfig(airflowv1alpha1.KubernetesExecutorsRoleName, mergedConfig)
+
+	name := common.GetKubernetesExecutorResourceName(r.GetClusterName())
+
+	options := func(o *builder.Options) {
+		o.ClusterName = r.RoleInfo.GetClusterName()
+		o.RoleName = r.RoleInfo.GetRoleName()
+
+		o.Labels = r.RoleInfo.GetLabels()
+		o.Annotations = r.RoleInfo.GetAnnotations()
+	}
+
+	podTemplate := common.NewPodTemplateReconciler(
+		r.Client,
+		name,
+		r.ClusterConfig,
+		mergedConfig,
+		r.Image,
+		r.Spec.OverridesSpec,
+		options,
+	)
+
+	sa := reconciler.NewSimpleResourceReconciler[builder.ServiceAccountBuilder](
+		r.Client,
+		builder.NewGenericServiceAccountBuilder(r.Client, name, options),
+	)
+
+	roleBuilder := builder.NewGenericRoleBuilder(r.Client, name, options)
+	roleBuilder.AddPolicyRules(executorPolicyRules)
+	role := reconciler.NewSimpleResourceReconciler[builder.RoleBuilder](r.Client, roleBuilder)
+
+	roleBindingBuilder := builder.NewGenericRoleBindingBuilder(r.Client, name, options)
+	roleBindingBuilder.AddSubject(name)
+	roleBindingBuilder.SetRoleRef(name, false)
+	roleBinding := reconciler.NewSimpleResourceReconciler[builder.RoleBindingBuilder](r.Client, roleBindingBuilder)
+
+	for _, resource := range []reconciler.Reconciler{podTemplate, sa, role, roleBinding} {
+		r.AddResource(resource)
+	}
+	return nil
+}
+
+// executorPolicyRules are the permissions the kubernetes executor needs to run and watch task pods
+var executorPolicyRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"create", "get", "list", "watch", "patch", "delete"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods/log"},
+		Verbs:     []string{"get", "list"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods/exec"},
+		Verbs:     []string{"create", "get"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+		Verbs:     []string{"list"},
+	},
+}
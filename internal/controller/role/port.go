@@ -1,17 +1,44 @@
 package role
 
-import corev1 "k8s.io/api/core/v1"
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
 
 var (
 	ports = []corev1.ContainerPort{
 		{
 			Name:          "http",
-			ContainerPort: 8080,
+			ContainerPort: common.WebserverPort,
 			Protocol:      corev1.ProtocolTCP,
 		},
 		{
 			Name:          "metrics",
-			ContainerPort: 9102, // statsd exporter port
+			ContainerPort: common.MetricsPort,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+
+	// the triggerer serves the logs of deferred tasks to the webserver
+	triggererPorts = []corev1.ContainerPort{
+		{
+			Name:          "logs",
+			ContainerPort: common.TriggererLogServerPort,
+			Protocol:      corev1.ProtocolTCP,
+		},
+		{
+			Name:          "metrics",
+			ContainerPort: common.MetricsPort,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+
+	// flower sends no metrics, only its UI is exposed
+	flowerPorts = []corev1.ContainerPort{
+		{
+			Name:          "http",
+			ContainerPort: common.FlowerPort,
 			Protocol:      corev1.ProtocolTCP,
 		},
 	}
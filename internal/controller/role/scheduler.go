@@ -20,6 +20,12 @@ type SchedulersReconciler struct {
 	reconciler.BaseRoleReconciler[*airflowv1alpha1.SchedulersSpec]
 	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
 	Image         *util.Image
+	Executor      common.ExecutorType
+	Celery        *common.CeleryBroker
+
+	// StandaloneDagProcessor is set when the cluster has dag processors,
+	// the scheduler then leaves DAG parsing to them.
+	StandaloneDagProcessor bool
 }
 
 func NewSchedulersReconciler(
@@ -28,12 +34,16 @@ func NewSchedulersReconciler(
 	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
 	roleInfo reconciler.RoleInfo,
 	image *util.Image,
+	executor common.ExecutorType,
+	celery *common.CeleryBroker,
 	spec *airflowv1alpha1.SchedulersSpec,
 ) *SchedulersReconciler {
 	return &SchedulersReconciler{
 		BaseRoleReconciler: *reconciler.NewBaseRoleReconciler(client, clusterStopped, roleInfo, spec),
 		ClusterConfig:      clusterConfig,
 		Image:              image,
+		Executor:           executor,
+		Celery:             celery,
 	}
 }
 
@@ -43,6 +53,7 @@ func (r *SchedulersReconciler) RegisterResources(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		mergedRoleGroupConfig = common.DefaultConfig(airflowv1alpha1.SchedulersRoleName, mergedRoleGroupConfig)
 
 		mergedOverrides, err := util.MergeObject(r.Spec.OverridesSpec, roleGroup.OverridesSpec)
 		if err != nil {
@@ -77,12 +88,6 @@ func (r *SchedulersReconciler) RegisterResourceWithRoleGroup(
 
 	var auth *common.Authentication
 	var err error
-	executorType := common.CeleryExecutor
-
-	var commonsRoleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
-	if config != nil {
-		commonsRoleGroupConfig = config.RoleGroupConfigSpec
-	}
 
 	if len(r.ClusterConfig.Authentication) > 0 {
 		auth, err = common.NewAuthentication(ctx, r.Client, r.ClusterConfig.Authentication)
@@ -106,26 +111,27 @@ func (r *SchedulersReconciler) RegisterResourceWithRoleGroup(
 		config,
 		info,
 		auth,
+		r.Celery,
 		options,
 	)
 
-	deploymentReconciler, err := common.NewStatefulSetReconciler(
+	statefulSetBuilder := common.NewStatefulSetBuilder(
 		r.Client,
-		info,
+		info.GetFullName(),
 		r.ClusterConfig,
-		make([]corev1.ContainerPort, 0),
-		r.Image,
 		replicas,
-		r.ClusterStopped(),
+		r.Image,
+		make([]corev1.ContainerPort, 0),
 		overrides,
-		commonsRoleGroupConfig,
-		executorType,
+		config,
+		r.Executor,
+		r.Celery,
 		auth,
 		options,
 	)
-	if err != nil {
-		return nil, err
-	}
+	statefulSetBuilder.StandaloneDagProcessor = r.StandaloneDagProcessor
+
+	deploymentReconciler := reconciler.NewStatefulSet(r.Client, statefulSetBuilder, r.ClusterStopped())
 
 	metricsSvc := common.GetServiceReconciler(r, info, ports)
 
@@ -8,6 +8,7 @@ import (
 	"github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
 	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
 
 	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
 	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
@@ -19,6 +20,8 @@ type WebserversReconciler struct {
 	reconciler.BaseRoleReconciler[*airflowv1alpha1.WebserversSpec]
 	ClusterConfig *airflowv1alpha1.ClusterConfigSpec
 	Image         *util.Image
+	Executor      common.ExecutorType
+	Celery        *common.CeleryBroker
 }
 
 func NewWebserversReconciler(
@@ -27,12 +30,16 @@ func NewWebserversReconciler(
 	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
 	roleInfo reconciler.RoleInfo,
 	image *util.Image,
+	executor common.ExecutorType,
+	celery *common.CeleryBroker,
 	spec *airflowv1alpha1.WebserversSpec,
 ) *WebserversReconciler {
 	return &WebserversReconciler{
 		BaseRoleReconciler: *reconciler.NewBaseRoleReconciler(client, clusterStopped, roleInfo, spec),
 		ClusterConfig:      clusterConfig,
 		Image:              image,
+		Executor:           executor,
+		Celery:             celery,
 	}
 }
 
@@ -42,6 +49,7 @@ func (r *WebserversReconciler) RegisterResources(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		mergedRoleGroupConfig = common.DefaultConfig(airflowv1alpha1.WebserversRoleName, mergedRoleGroupConfig)
 
 		mergedOverrides, err := util.MergeObject(r.Spec.OverridesSpec, roleGroup.OverridesSpec)
 		if err != nil {
@@ -63,9 +71,33 @@ func (r *WebserversReconciler) RegisterResources(ctx context.Context) error {
 			r.AddResource(reconciler)
 		}
 	}
+
+	if common.IsAirflow3(r.Image.ProductVersion) {
+		r.AddResource(r.getExecutionAPIService())
+	}
 	return nil
 }
 
+// getExecutionAPIService returns the service selecting the api-servers of every role group,
+// airflow 3 task runners report to the execution api of any of them.
+func (r *WebserversReconciler) getExecutionAPIService() reconciler.Reconciler {
+	return reconciler.NewServiceReconciler(
+		r.Client,
+		common.GetWebserverServiceName(r.RoleInfo.GetClusterName()),
+		[]corev1.ContainerPort{
+			{
+				Name:          "http",
+				ContainerPort: common.WebserverPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		func(o *builder.ServiceBuilderOptions) {
+			o.Labels = r.RoleInfo.GetLabels()
+			o.Annotations = r.RoleInfo.GetAnnotations()
+		},
+	)
+}
+
 func (r *WebserversReconciler) RegisterResourceWithRoleGroup(
 	ctx context.Context,
 	info reconciler.RoleGroupInfo,
@@ -76,12 +108,6 @@ func (r *WebserversReconciler) RegisterResourceWithRoleGroup(
 
 	var auth *common.Authentication
 	var err error
-	executorType := common.CeleryExecutor
-
-	var commonsRoleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
-	if config != nil {
-		commonsRoleGroupConfig = config.RoleGroupConfigSpec
-	}
 
 	if len(r.ClusterConfig.Authentication) > 0 {
 		auth, err = common.NewAuthentication(ctx, r.Client, r.ClusterConfig.Authentication)
@@ -105,6 +131,7 @@ func (r *WebserversReconciler) RegisterResourceWithRoleGroup(
 		config,
 		info,
 		auth,
+		r.Celery,
 		options,
 	)
 
@@ -117,8 +144,9 @@ func (r *WebserversReconciler) RegisterResourceWithRoleGroup(
 		replicas,
 		r.ClusterStopped(),
 		overrides,
-		commonsRoleGroupConfig,
-		executorType,
+		config,
+		r.Executor,
+		r.Celery,
 		auth,
 		options,
 	)
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+const (
+	ConditionReasonAvailable             = "Available"
+	ConditionReasonUnavailable           = "Unavailable"
+	ConditionReasonRollingOut            = "RollingOut"
+	ConditionReasonRolledOut             = "RolledOut"
+	ConditionReasonStopped               = "Stopped"
+	ConditionReasonReconcileError        = "ReconcileError"
+	ConditionReasonReconcileSucceeded    = "ReconcileSucceeded"
+	ConditionReasonReconciliationPaused  = "ReconciliationPaused"
+	ConditionReasonReconciliationRunning = "ReconciliationRunning"
+)
+
+// getRoleGroupNames returns the role groups declared in the spec, keyed by role name.
+func getRoleGroupNames(spec *airflowv1alpha1.AirflowClusterSpec) map[airflowv1alpha1.RoleName][]string {
+	roleGroups := make(map[airflowv1alpha1.RoleName][]string)
+	if spec.Schedulers != nil {
+		roleGroups[airflowv1alpha1.SchedulersRoleName] = slices.Sorted(maps.Keys(spec.Schedulers.RoleGroups))
+	}
+	if spec.Webservers != nil {
+		roleGroups[airflowv1alpha1.WebserversRoleName] = slices.Sorted(maps.Keys(spec.Webservers.RoleGroups))
+	}
+	if dagProcessors := getDagProcessors(spec); dagProcessors != nil {
+		roleGroups[airflowv1alpha1.DagProcessorsRoleName] = slices.Sorted(maps.Keys(dagProcessors.RoleGroups))
+	}
+	if spec.Triggerers != nil {
+		roleGroups[airflowv1alpha1.TriggerersRoleName] = slices.Sorted(maps.Keys(spec.Triggerers.RoleGroups))
+	}
+	if spec.CeleryExecutors != nil {
+		roleGroups[airflowv1alpha1.CeleryExecutorsRoleName] = slices.Sorted(maps.Keys(spec.CeleryExecutors.RoleGroups))
+	}
+	if spec.Flower != nil && spec.CeleryExecutors != nil {
+		roleGroups[airflowv1alpha1.FlowerRoleName] = slices.Sorted(maps.Keys(spec.Flower.RoleGroups))
+	}
+	return roleGroups
+}
+
+// getRoleGroupStatuses reads the StatefulSet of every role group and reports its replicas,
+// together with the role groups which are not ready. A role group is ready once its StatefulSet
+// exists and has as many ready replicas as wanted, a role group scaled to zero is ready.
+// A StatefulSet that has not been created yet is reported with zero replicas.
+func (r *AirflowClusterReconciler) getRoleGroupStatuses(
+	ctx context.Context,
+	instance *airflowv1alpha1.AirflowCluster,
+	clusterInfo reconciler.ClusterInfo,
+) ([]airflowv1alpha1.RoleGroupStatus, []string, error) {
+	roleGroups := getRoleGroupNames(&instance.Spec)
+	statuses := make([]airflowv1alpha1.RoleGroupStatus, 0)
+	notReady := make([]string, 0)
+	for _, roleName := range slices.Sorted(maps.Keys(roleGroups)) {
+		for _, roleGroupName := range roleGroups[roleName] {
+			info := reconciler.RoleGroupInfo{
+				RoleInfo:      reconciler.RoleInfo{ClusterInfo: clusterInfo, RoleName: string(roleName)},
+				RoleGroupName: roleGroupName,
+			}
+			status := airflowv1alpha1.RoleGroupStatus{
+				Role:      string(roleName),
+				RoleGroup: roleGroupName,
+			}
+
+			sts := &appv1.StatefulSet{}
+			err := r.Get(ctx, ctrlclient.ObjectKey{Namespace: instance.Namespace, Name: info.GetFullName()}, sts)
+			if ctrlclient.IgnoreNotFound(err) != nil {
+				return nil, nil, err
+			}
+			if err == nil {
+				if sts.Spec.Replicas != nil {
+					status.Replicas = *sts.Spec.Replicas
+				}
+				status.ReadyReplicas = sts.Status.ReadyReplicas
+			}
+			if err != nil || status.ReadyReplicas < status.Replicas {
+				notReady = append(notReady, status.Role+"/"+status.RoleGroup)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, notReady, nil
+}
+
+// getDagsGitSyncStatuses reports for every DAG repo how many pods synced it,
+// based on the state of the git-sync containers.
+func (r *AirflowClusterReconciler) getDagsGitSyncStatuses(
+	ctx context.Context,
+	instance *airflowv1alpha1.AirflowCluster,
+) ([]airflowv1alpha1.DagsGitSyncStatus, error) {
+	gitSyncs := common.GetGitSyncs(instance.Spec.ClusterConfig)
+	if len(gitSyncs) == 0 {
+		return nil, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods,
+		ctrlclient.InNamespace(instance.Namespace),
+		ctrlclient.MatchingLabels{constants.LabelKubernetesInstance: instance.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]airflowv1alpha1.DagsGitSyncStatus, 0, len(gitSyncs))
+	for _, gitSync := range gitSyncs {
+		status := airflowv1alpha1.DagsGitSyncStatus{
+			Name: gitSync.GetName(),
+			Repo: gitSync.Spec.Repo,
+			Path: gitSync.GetDagFolder(),
+		}
+		for _, pod := range pods.Items {
+			for _, cs := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+				if cs.Name != gitSync.GetInitContainerName() && cs.Name != gitSync.GetSidecarContainerName() {
+					continue
+				}
+				if message, failed := getGitSyncFailure(cs); failed {
+					status.FailedPods++
+					if message != "" {
+						status.Message = message
+					}
+					break
+				}
+				if cs.Name == gitSync.GetSidecarContainerName() && cs.State.Running != nil {
+					status.SyncedPods++
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// getGitSyncFailure returns the error message of a failed git-sync container
+func getGitSyncFailure(cs corev1.ContainerStatus) (string, bool) {
+	if t := cs.State.Terminated; t != nil && t.ExitCode != 0 {
+		return t.Message, true
+	}
+	if w := cs.State.Waiting; w != nil && w.Reason == "CrashLoopBackOff" {
+		if t := cs.LastTerminationState.Terminated; t != nil && t.Message != "" {
+			return t.Message, true
+		}
+		return w.Message, true
+	}
+	return "", false
+}
+
+// getDatabaseMigrationStatus reports the migration job of the product version of the cluster
+func (r *AirflowClusterReconciler) getDatabaseMigrationStatus(
+	ctx context.Context,
+	instance *airflowv1alpha1.AirflowCluster,
+) (*airflowv1alpha1.DatabaseMigrationStatus, error) {
+	productVersion := getImage(instance.Spec.Image).ProductVersion
+	status := &airflowv1alpha1.DatabaseMigrationStatus{
+		ProductVersion: productVersion,
+		JobName:        common.GetMigrationJobName(instance.Name, productVersion),
+		Phase:          airflowv1alpha1.DatabaseMigrationPending,
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, ctrlclient.ObjectKey{Namespace: instance.Namespace, Name: status.JobName}, job)
+	if ctrlclient.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	if err == nil {
+		status.Phase, status.Message = common.GetMigrationJobPhase(job)
+	}
+	return status, nil
+}
+
+// updateStatus records the outcome of a reconcile in the AirflowCluster status.
+// It is called after every reconcile, whether it failed, requeued or completed.
+func (r *AirflowClusterReconciler) updateStatus(
+	ctx context.Context,
+	instance *airflowv1alpha1.AirflowCluster,
+	clusterInfo reconciler.ClusterInfo,
+	result ctrl.Result,
+	reconcileErr error,
+) error {
+	roleGroups, notReady, err := r.getRoleGroupStatuses(ctx, instance, clusterInfo)
+	if err != nil {
+		return err
+	}
+
+	dagsGitSync, err := r.getDagsGitSyncStatuses(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	databaseMigration, err := r.getDatabaseMigrationStatus(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	webservers, err := common.GetWebserverAddresses(
+		ctx,
+		r.Client,
+		instance.Namespace,
+		instance.Name,
+		instance.Spec.ClusterConfig,
+		getRoleGroupNames(&instance.Spec)[airflowv1alpha1.WebserversRoleName],
+	)
+	if err != nil {
+		return err
+	}
+
+	patch := ctrlclient.MergeFrom(instance.DeepCopy())
+	status := &instance.Status
+	status.ObservedGeneration = instance.Generation
+	status.RoleGroups = roleGroups
+	status.DagsGitSync = dagsGitSync
+	status.DatabaseMigration = databaseMigration
+	status.Webservers = webservers
+
+	paused := instance.Spec.ClusterOperation != nil && instance.Spec.ClusterOperation.ReconciliationPaused
+	if paused {
+		setCondition(instance, airflowv1alpha1.ConditionTypeReconciliationPaused, metav1.ConditionTrue,
+			ConditionReasonReconciliationPaused, "Reconciliation is paused by clusterOperation.reconciliationPaused")
+	} else {
+		setCondition(instance, airflowv1alpha1.ConditionTypeReconciliationPaused, metav1.ConditionFalse,
+			ConditionReasonReconciliationRunning, "Reconciliation is running")
+	}
+
+	if reconcileErr != nil {
+		setCondition(instance, airflowv1alpha1.ConditionTypeDegraded, metav1.ConditionTrue,
+			ConditionReasonReconcileError, reconcileErr.Error())
+	} else {
+		setCondition(instance, airflowv1alpha1.ConditionTypeDegraded, metav1.ConditionFalse,
+			ConditionReasonReconcileSucceeded, "Reconcile succeeded")
+	}
+
+	stopped := instance.Spec.ClusterOperation != nil && instance.Spec.ClusterOperation.Stopped
+	switch {
+	case stopped:
+		// the role groups are scaled to zero, the cluster serves nothing even once they are
+		setCondition(instance, airflowv1alpha1.ConditionTypeAvailable, metav1.ConditionFalse,
+			ConditionReasonStopped, "The cluster is stopped by clusterOperation.stopped")
+	case len(roleGroups) == 0:
+		setCondition(instance, airflowv1alpha1.ConditionTypeAvailable, metav1.ConditionFalse,
+			ConditionReasonUnavailable, "No role groups are defined")
+	case len(notReady) == 0:
+		setCondition(instance, airflowv1alpha1.ConditionTypeAvailable, metav1.ConditionTrue,
+			ConditionReasonAvailable, "All role groups are ready")
+	default:
+		setCondition(instance, airflowv1alpha1.ConditionTypeAvailable, metav1.ConditionFalse,
+			ConditionReasonUnavailable, fmt.Sprintf("Role groups not ready: %s", strings.Join(notReady, ", ")))
+	}
+
+	if !paused && (len(notReady) > 0 || !result.IsZero()) {
+		setCondition(instance, airflowv1alpha1.ConditionTypeProgressing, metav1.ConditionTrue,
+			ConditionReasonRollingOut, "Resources are being rolled out")
+	} else {
+		setCondition(instance, airflowv1alpha1.ConditionTypeProgressing, metav1.ConditionFalse,
+			ConditionReasonRolledOut, "No rollout in progress")
+	}
+
+	return r.Status().Patch(ctx, instance, patch)
+}
+
+func setCondition(instance *airflowv1alpha1.AirflowCluster, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	apimeta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: instance.Generation,
+	})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+const (
+	DefaultReplicas      int32 = 1
+	DefaultRoleGroupName       = "default"
+)
+
+func defaultSpec(spec *airflowv1alpha1.AirflowClusterSpec) {
+	spec.Image = defaultImage(spec.Image)
+
+	if spec.ClusterConfig == nil {
+		spec.ClusterConfig = &airflowv1alpha1.ClusterConfigSpec{}
+	}
+
+	if spec.Schedulers == nil {
+		spec.Schedulers = &airflowv1alpha1.SchedulersSpec{}
+	}
+	spec.Schedulers.RoleGroups = defaultRoleGroups(spec.Schedulers.RoleGroups)
+	spec.Schedulers.Config = common.DefaultConfig(airflowv1alpha1.SchedulersRoleName, spec.Schedulers.Config)
+
+	if spec.Webservers == nil {
+		spec.Webservers = &airflowv1alpha1.WebserversSpec{}
+	}
+	spec.Webservers.RoleGroups = defaultRoleGroups(spec.Webservers.RoleGroups)
+	spec.Webservers.Config = common.DefaultConfig(airflowv1alpha1.WebserversRoleName, spec.Webservers.Config)
+
+	if spec.Triggerers != nil {
+		spec.Triggerers.RoleGroups = defaultRoleGroups(spec.Triggerers.RoleGroups)
+		spec.Triggerers.Config = common.DefaultConfig(airflowv1alpha1.TriggerersRoleName, spec.Triggerers.Config)
+	}
+
+	// airflow 3 only parses DAGs in the dag processor
+	if spec.DagProcessors == nil && common.IsAirflow3(spec.Image.ProductVersion) {
+		spec.DagProcessors = &airflowv1alpha1.DagProcessorsSpec{}
+	}
+	if spec.DagProcessors != nil {
+		spec.DagProcessors.RoleGroups = defaultRoleGroups(spec.DagProcessors.RoleGroups)
+		spec.DagProcessors.Config = common.DefaultConfig(airflowv1alpha1.DagProcessorsRoleName, spec.DagProcessors.Config)
+	}
+
+	// executors are optional, their presence selects the executor
+	if spec.CeleryExecutors != nil {
+		spec.CeleryExecutors.RoleGroups = defaultCeleryRoleGroups(spec.CeleryExecutors.RoleGroups)
+		spec.CeleryExecutors.Config = defaultCeleryConfig(spec.CeleryExecutors.Config)
+	}
+	if spec.KubernetesExecutors != nil {
+		spec.KubernetesExecutors.Config = common.DefaultConfig(airflowv1alpha1.KubernetesExecutorsRoleName, spec.KubernetesExecutors.Config)
+	}
+
+	if spec.Flower != nil {
+		spec.Flower.RoleGroups = defaultRoleGroups(spec.Flower.RoleGroups)
+		spec.Flower.Config = common.DefaultConfig(airflowv1alpha1.FlowerRoleName, spec.Flower.Config)
+	}
+}
+
+// defaultImage leaves KubedoopVersion empty, so the image matching the operator version is used.
+// The product version of a custom image is not defaulted, the validator requires it.
+func defaultImage(image *airflowv1alpha1.ImageSpec) *airflowv1alpha1.ImageSpec {
+	if image == nil {
+		image = &airflowv1alpha1.ImageSpec{}
+	}
+	if image.Custom == "" {
+		if image.Repo == "" {
+			image.Repo = airflowv1alpha1.DefaultRepository
+		}
+		if image.ProductVersion == "" {
+			image.ProductVersion = airflowv1alpha1.DefaultProductVersion
+		}
+	}
+	if image.PullPolicy == "" {
+		image.PullPolicy = corev1.PullIfNotPresent
+	}
+	return image
+}
+
+func defaultRoleGroups(roleGroups map[string]airflowv1alpha1.RoleGroupSpec) map[string]airflowv1alpha1.RoleGroupSpec {
+	if len(roleGroups) == 0 {
+		return map[string]airflowv1alpha1.RoleGroupSpec{
+			DefaultRoleGroupName: {Replicas: ptr.To(DefaultReplicas)},
+		}
+	}
+	for name, roleGroup := range roleGroups {
+		if roleGroup.Replicas == nil {
+			roleGroup.Replicas = ptr.To(DefaultReplicas)
+			roleGroups[name] = roleGroup
+		}
+	}
+	return roleGroups
+}
+
+// defaultCeleryRoleGroups defaults the replicas of the celery role groups, they are not used while autoscaling
+func defaultCeleryRoleGroups(roleGroups map[string]airflowv1alpha1.CeleryRoleGroupSpec) map[string]airflowv1alpha1.CeleryRoleGroupSpec {
+	if len(roleGroups) == 0 {
+		return map[string]airflowv1alpha1.CeleryRoleGroupSpec{
+			DefaultRoleGroupName: {Replicas: ptr.To(DefaultReplicas)},
+		}
+	}
+	for name, roleGroup := range roleGroups {
+		if roleGroup.Replicas == nil {
+			roleGroup.Replicas = ptr.To(DefaultReplicas)
+			roleGroups[name] = roleGroup
+		}
+	}
+	return roleGroups
+}
+
+// defaultCeleryConfig sets the resources and logging of the workers, the queues are left to airflow
+func defaultCeleryConfig(config *airflowv1alpha1.CeleryConfigSpec) *airflowv1alpha1.CeleryConfigSpec {
+	if config == nil {
+		config = &airflowv1alpha1.CeleryConfigSpec{}
+	}
+	config.ConfigSpec = *common.DefaultConfig(airflowv1alpha1.CeleryExecutorsRoleName, &config.ConfigSpec)
+	return config
+}
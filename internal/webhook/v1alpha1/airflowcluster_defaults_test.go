@@ -0,0 +1,139 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+var _ = Describe("AirflowCluster Defaulting Webhook", func() {
+	var (
+		ctx       = context.Background()
+		obj       *airflowv1alpha1.AirflowCluster
+		defaulter = &AirflowClusterCustomDefaulter{}
+	)
+
+	BeforeEach(func() {
+		obj = &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+		}
+	})
+
+	It("materialises image, roles and role groups", func() {
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		Expect(obj.Spec.Image).NotTo(BeNil())
+		Expect(obj.Spec.Image.Repo).To(Equal(airflowv1alpha1.DefaultRepository))
+		Expect(obj.Spec.Image.ProductVersion).To(Equal(airflowv1alpha1.DefaultProductVersion))
+		Expect(obj.Spec.Image.PullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(obj.Spec.ClusterConfig).NotTo(BeNil())
+
+		Expect(obj.Spec.Schedulers).NotTo(BeNil())
+		Expect(obj.Spec.Schedulers.RoleGroups).To(HaveKeyWithValue(DefaultRoleGroupName,
+			airflowv1alpha1.RoleGroupSpec{Replicas: ptr.To(DefaultReplicas)}))
+		Expect(obj.Spec.Webservers).NotTo(BeNil())
+		Expect(obj.Spec.CeleryExecutors).To(BeNil())
+		Expect(obj.Spec.KubernetesExecutors).To(BeNil())
+		Expect(obj.Spec.Triggerers).To(BeNil())
+
+		config := obj.Spec.Webservers.Config
+		Expect(config.Resources.Memory.Limit.String()).To(Equal("2Gi"))
+		Expect(config.Logging.Containers).To(HaveKey(string(airflowv1alpha1.WebserversRoleName)))
+		Expect(config.Logging.Containers[string(airflowv1alpha1.WebserversRoleName)].Console.Level).To(Equal("INFO"))
+		Expect(config.GracefulShutdownTimeout).To(Equal("2m"))
+	})
+
+	It("keeps the values set by the user", func() {
+		obj.Spec.CeleryExecutors = &airflowv1alpha1.CeleryExecutorsSpec{
+			RoleGroups: map[string]airflowv1alpha1.CeleryRoleGroupSpec{
+				"workers": {Replicas: ptr.To[int32](3)},
+				"spare":   {},
+			},
+			Config: &airflowv1alpha1.CeleryConfigSpec{
+				ConfigSpec: airflowv1alpha1.ConfigSpec{
+					RoleGroupConfigSpec: &commonsv1alpha1.RoleGroupConfigSpec{
+						GracefulShutdownTimeout: "1h",
+						Resources: &commonsv1alpha1.ResourcesSpec{
+							CPU: &commonsv1alpha1.CPUResource{Max: resource.MustParse("4")},
+						},
+					},
+				},
+				Queues: []string{"default", "gpu"},
+			},
+		}
+
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		roleGroups := obj.Spec.CeleryExecutors.RoleGroups
+		Expect(*roleGroups["workers"].Replicas).To(Equal(int32(3)))
+		Expect(*roleGroups["spare"].Replicas).To(Equal(DefaultReplicas))
+
+		cpu := obj.Spec.CeleryExecutors.Config.Resources.CPU
+		Expect(cpu.Max.String()).To(Equal("4"))
+		Expect(cpu.Min.String()).To(Equal("500m"))
+		Expect(obj.Spec.CeleryExecutors.Config.Queues).To(Equal([]string{"default", "gpu"}))
+		Expect(obj.Spec.CeleryExecutors.Config.GracefulShutdownTimeout).To(Equal("1h"))
+	})
+
+	It("defaults the flower role", func() {
+		obj.Spec.Flower = &airflowv1alpha1.FlowerSpec{CredentialsSecret: "flower"}
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		Expect(obj.Spec.Flower.RoleGroups).To(HaveKeyWithValue(DefaultRoleGroupName,
+			airflowv1alpha1.RoleGroupSpec{Replicas: ptr.To(DefaultReplicas)}))
+		Expect(obj.Spec.Flower.Config.Resources.Memory.Limit.String()).To(Equal("512Mi"))
+		Expect(obj.Spec.Flower.Config.GracefulShutdownTimeout).To(Equal("30s"))
+	})
+
+	It("defaults the role groups of an optional role", func() {
+		obj.Spec.Triggerers = &airflowv1alpha1.TriggerersSpec{}
+
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		Expect(obj.Spec.Triggerers.RoleGroups).To(HaveKeyWithValue(DefaultRoleGroupName,
+			airflowv1alpha1.RoleGroupSpec{Replicas: ptr.To(DefaultReplicas)}))
+		Expect(obj.Spec.Triggerers.Config.Logging.Containers).To(HaveKey(string(airflowv1alpha1.TriggerersRoleName)))
+	})
+
+	It("adds dag processors to airflow 3 clusters", func() {
+		obj.Spec.Image = &airflowv1alpha1.ImageSpec{ProductVersion: "3.0.2"}
+
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		Expect(obj.Spec.DagProcessors).NotTo(BeNil())
+		Expect(obj.Spec.DagProcessors.RoleGroups).To(HaveKey(DefaultRoleGroupName))
+	})
+
+	It("defaults the resources of the dag processors", func() {
+		obj.Spec.DagProcessors = &airflowv1alpha1.DagProcessorsSpec{}
+
+		Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+		Expect(obj.Spec.DagProcessors.RoleGroups).To(HaveKey(DefaultRoleGroupName))
+		Expect(obj.Spec.DagProcessors.Config.Resources.Memory.Limit.String()).To(Equal("1Gi"))
+	})
+})
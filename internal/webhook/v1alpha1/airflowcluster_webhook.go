@@ -0,0 +1,615 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+	common "github.com/zncdatadev/airflow-operator/internal/controller/common"
+)
+
+var airflowclusterlog = logf.Log.WithName("airflowcluster-resource")
+
+// Keys the credentials secret must contain
+var (
+	requiredCredentialsKeys = []string{
+		"adminUser.username",
+		"adminUser.firstname",
+		"adminUser.lastname",
+		"adminUser.email",
+		"adminUser.password",
+		"appSecretKey",
+	}
+	// only required when clusterConfig.metadataDatabase is not set
+	metadataDatabaseCredentialsKey  = "connections.sqlalchemyDatabaseUri"
+	requiredDatabaseCredentialsKeys = []string{
+		common.DatabaseCredentialsUsernameKey,
+		common.DatabaseCredentialsPasswordKey,
+	}
+	requiredCeleryCredentialsKeys = []string{
+		"connections.celeryBrokerUrl",
+		"connections.celeryResultBackend",
+	}
+	requiredFlowerCredentialsKeys = []string{
+		common.FlowerCredentialsUsernameKey,
+		common.FlowerCredentialsPasswordKey,
+	}
+)
+
+// SetupAirflowClusterWebhookWithManager registers the webhook for AirflowCluster in the manager.
+func SetupAirflowClusterWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &airflowv1alpha1.AirflowCluster{}).
+		WithValidator(&AirflowClusterCustomValidator{Client: mgr.GetClient()}).
+		WithDefaulter(&AirflowClusterCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-airflow-kubedoop-dev-v1alpha1-airflowcluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=airflow.kubedoop.dev,resources=airflowclusters,verbs=create;update,versions=v1alpha1,name=mairflowcluster-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// AirflowClusterCustomDefaulter fills in the defaults of AirflowCluster, so the stored
+// object shows the effective configuration and the reconciler never meets a nil spec.
+type AirflowClusterCustomDefaulter struct{}
+
+var _ admission.Defaulter[*airflowv1alpha1.AirflowCluster] = &AirflowClusterCustomDefaulter{}
+
+func (d *AirflowClusterCustomDefaulter) Default(ctx context.Context, obj *airflowv1alpha1.AirflowCluster) error {
+	airflowclusterlog.Info("Defaulting for AirflowCluster", "name", obj.GetName())
+	defaultSpec(&obj.Spec)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-airflow-kubedoop-dev-v1alpha1-airflowcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=airflow.kubedoop.dev,resources=airflowclusters,verbs=create;update,versions=v1alpha1,name=vairflowcluster-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// AirflowClusterCustomValidator validates AirflowCluster when it is created or updated.
+// It looks up the Secrets and AuthenticationClasses referenced by the cluster, so
+// errors which would otherwise only show up during reconcile are reported at apply time.
+// On update, missing references are reported as warnings instead.
+type AirflowClusterCustomValidator struct {
+	Client client.Client
+}
+
+var _ admission.Validator[*airflowv1alpha1.AirflowCluster] = &AirflowClusterCustomValidator{}
+
+func (v *AirflowClusterCustomValidator) ValidateCreate(ctx context.Context, obj *airflowv1alpha1.AirflowCluster) (admission.Warnings, error) {
+	airflowclusterlog.Info("Validation for AirflowCluster upon creation", "name", obj.GetName())
+	return v.validate(ctx, obj, false)
+}
+
+func (v *AirflowClusterCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj *airflowv1alpha1.AirflowCluster) (admission.Warnings, error) {
+	airflowclusterlog.Info("Validation for AirflowCluster upon update", "name", newObj.GetName())
+	return v.validate(ctx, newObj, true)
+}
+
+func (v *AirflowClusterCustomValidator) ValidateDelete(ctx context.Context, obj *airflowv1alpha1.AirflowCluster) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate rejects the invalid fields of the cluster. Missing or incomplete referenced objects
+// only reject a new cluster, on update they are warnings, so an update is not blocked while
+// a Secret or AuthenticationClass is still missing, including the update that fixes the reference.
+func (v *AirflowClusterCustomValidator) validate(ctx context.Context, obj *airflowv1alpha1.AirflowCluster, update bool) (admission.Warnings, error) {
+	allErrs, refErrs, err := v.validateSpec(ctx, obj.Namespace, &obj.Spec, field.NewPath("spec"))
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings admission.Warnings
+	if update {
+		for _, refErr := range refErrs {
+			warnings = append(warnings, refErr.Error())
+		}
+	} else {
+		allErrs = append(allErrs, refErrs...)
+	}
+
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(airflowv1alpha1.GroupVersion.WithKind("AirflowCluster").GroupKind(), obj.Name, allErrs)
+}
+
+// validateSpec returns the field errors of the spec and the errors of the objects it references,
+// the error is only set when a lookup failed.
+func (v *AirflowClusterCustomValidator) validateSpec(
+	ctx context.Context,
+	namespace string,
+	spec *airflowv1alpha1.AirflowClusterSpec,
+	path *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+
+	if spec.CeleryExecutors != nil && spec.KubernetesExecutors != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("kubernetesExecutors"),
+			"celeryExecutors and kubernetesExecutors can not be set at the same time"))
+	}
+
+	if spec.Image != nil && spec.Image.ProductVersion != "" {
+		if _, err := common.GetAirflowVersion(spec.Image.ProductVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("image", "productVersion"), spec.Image.ProductVersion, err.Error()))
+		}
+	} else if spec.Image != nil && spec.Image.Custom != "" {
+		// the version of a custom image can not be guessed, it selects the airflow 2 or 3 configuration
+		allErrs = append(allErrs, field.Required(path.Child("image", "productVersion"), "productVersion is required with a custom image"))
+	}
+
+	clusterConfigPath := path.Child("clusterConfig")
+	if spec.ClusterConfig == nil {
+		return append(allErrs, field.Required(clusterConfigPath, "")), nil, nil
+	}
+
+	errs, refs, err := v.validateCredentials(ctx, namespace, spec, clusterConfigPath.Child("credentialsSecret"), path.Child("celeryExecutors"))
+	if err != nil {
+		return nil, nil, err
+	}
+	allErrs, refErrs = append(allErrs, errs...), append(refErrs, refs...)
+
+	errs, refs, err = v.validateAuthentication(ctx, spec.ClusterConfig.Authentication, clusterConfigPath.Child("authentication"))
+	if err != nil {
+		return nil, nil, err
+	}
+	allErrs, refErrs = append(allErrs, errs...), append(refErrs, refs...)
+
+	errs, refs, err = v.validateMetadataDatabase(ctx, namespace, spec.ClusterConfig.MetadataDatabase, clusterConfigPath.Child("metadataDatabase"))
+	if err != nil {
+		return nil, nil, err
+	}
+	allErrs, refErrs = append(allErrs, errs...), append(refErrs, refs...)
+
+	if spec.CeleryExecutors != nil {
+		errs, refs, err = v.validateCeleryBroker(ctx, namespace, spec.CeleryExecutors, path.Child("celeryExecutors"))
+		if err != nil {
+			return nil, nil, err
+		}
+		allErrs, refErrs = append(allErrs, errs...), append(refErrs, refs...)
+
+		allErrs = append(allErrs, v.validateCeleryAutoscaling(spec.ClusterConfig, spec.CeleryExecutors, path.Child("celeryExecutors"))...)
+	}
+
+	if spec.Flower != nil {
+		flowerPath := path.Child("flower")
+		if spec.CeleryExecutors == nil {
+			allErrs = append(allErrs, field.Forbidden(flowerPath, "flower requires celeryExecutors"))
+		}
+		refs, err = v.validateSecretKeys(ctx, namespace, spec.Flower.CredentialsSecret, requiredFlowerCredentialsKeys, flowerPath.Child("credentialsSecret"))
+		if err != nil {
+			return nil, nil, err
+		}
+		refErrs = append(refErrs, refs...)
+	}
+
+	errs, refs, err = v.validateDagsGitSync(ctx, namespace, spec.ClusterConfig, clusterConfigPath.Child("dagsGitSync"))
+	if err != nil {
+		return nil, nil, err
+	}
+	allErrs, refErrs = append(allErrs, errs...), append(refErrs, refs...)
+
+	allErrs = append(allErrs, v.validateVolumes(spec.ClusterConfig, clusterConfigPath)...)
+	allErrs = append(allErrs, v.validateGracefulShutdownTimeouts(spec, path)...)
+
+	return allErrs, refErrs, nil
+}
+
+func (v *AirflowClusterCustomValidator) validateCredentials(
+	ctx context.Context,
+	namespace string,
+	spec *airflowv1alpha1.AirflowClusterSpec,
+	path *field.Path,
+	celeryPath *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+	name := spec.ClusterConfig.Credentials
+	if name == "" {
+		return append(allErrs, field.Required(path, "")), nil, nil
+	}
+
+	secret, err := v.getSecret(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if secret == nil {
+		return nil, append(refErrs, field.NotFound(path, name)), nil
+	}
+
+	keys := requiredCredentialsKeys
+	if spec.ClusterConfig.MetadataDatabase == nil {
+		keys = append(slices.Clone(keys), metadataDatabaseCredentialsKey)
+	}
+	for _, key := range keys {
+		if _, ok := secret.Data[key]; !ok {
+			refErrs = append(refErrs, field.Invalid(path, name, "secret is missing key "+key))
+		}
+	}
+
+	if spec.CeleryExecutors != nil && spec.CeleryExecutors.Broker == nil {
+		for _, key := range requiredCeleryCredentialsKeys {
+			if _, ok := secret.Data[key]; !ok {
+				refErrs = append(refErrs, field.Required(celeryPath,
+					"celery executors need a broker, credentials secret "+name+" is missing key "+key))
+			}
+		}
+	}
+
+	return nil, refErrs, nil
+}
+
+// validateAuthentication checks the referenced AuthenticationClasses exist, are supported and
+// referenced once, that ldap and oidc are not mixed, and that the settings shared by all providers
+// have the same value in every entry.
+func (v *AirflowClusterCustomValidator) validateAuthentication(
+	ctx context.Context,
+	auths []airflowv1alpha1.AuthenticationSpec,
+	path *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+	classes := make(map[string]bool)
+	var ldapPath, oidcPath *field.Path
+	for i, auth := range auths {
+		authPath := path.Index(i)
+		classPath := authPath.Child("authenticationClass")
+
+		if auth.AuthenticationClass == "" {
+			allErrs = append(allErrs, field.Required(classPath, ""))
+		} else if classes[auth.AuthenticationClass] {
+			allErrs = append(allErrs, field.Duplicate(classPath, auth.AuthenticationClass))
+		} else {
+			classes[auth.AuthenticationClass] = true
+			authClass := &authv1alpha1.AuthenticationClass{}
+			if err := v.Client.Get(ctx, client.ObjectKey{Name: auth.AuthenticationClass}, authClass); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, nil, err
+				}
+				refErrs = append(refErrs, field.NotFound(classPath, auth.AuthenticationClass))
+			} else if provider := authClass.Spec.AuthenticationProvider; provider == nil || (provider.OIDC == nil && provider.LDAP == nil) {
+				allErrs = append(allErrs, field.Invalid(classPath, auth.AuthenticationClass,
+					"only ldap and oidc authentication providers are supported"))
+			} else {
+				if provider.OIDC != nil && oidcPath == nil {
+					oidcPath = classPath
+				}
+				if provider.LDAP != nil && ldapPath == nil {
+					ldapPath = classPath
+				}
+				if provider.OIDC != nil && auth.Oidc == nil {
+					allErrs = append(allErrs, field.Required(authPath.Child("oidc"),
+						"oidc is required for an oidc authentication class"))
+				} else if provider.OIDC == nil && auth.RolesClaim != "" {
+					allErrs = append(allErrs, field.Forbidden(authPath.Child("rolesClaim"),
+						"rolesClaim is only used by oidc authentication classes"))
+				}
+				if auth.LdapStartTLS && (provider.LDAP == nil || provider.LDAP.TLS == nil) {
+					allErrs = append(allErrs, field.Forbidden(authPath.Child("ldapStartTLS"),
+						"ldapStartTLS is only used by ldap authentication classes with tls"))
+				}
+			}
+		}
+
+		for _, group := range slices.Sorted(maps.Keys(auth.RoleMapping)) {
+			if len(auth.RoleMapping[group]) == 0 {
+				allErrs = append(allErrs, field.Required(authPath.Child("roleMapping").Key(group),
+					"at least one role is required"))
+			}
+		}
+
+		if i == 0 {
+			continue
+		}
+		first := auths[0]
+		if auth.SyncRolesAt != first.SyncRolesAt {
+			allErrs = append(allErrs, field.Invalid(authPath.Child("syncRolesAt"), auth.SyncRolesAt,
+				"syncRolesAt must be the same for all authentication providers"))
+		}
+		if auth.UserRegistration != first.UserRegistration {
+			allErrs = append(allErrs, field.Invalid(authPath.Child("userRegistration"), auth.UserRegistration,
+				"userRegistration must be the same for all authentication providers"))
+		}
+		if auth.UserRegistrationRole != first.UserRegistrationRole {
+			allErrs = append(allErrs, field.Invalid(authPath.Child("userRegistrationRole"), auth.UserRegistrationRole,
+				"userRegistrationRole must be the same for all authentication providers"))
+		}
+	}
+
+	if ldapPath != nil && oidcPath != nil {
+		allErrs = append(allErrs, field.Forbidden(oidcPath, "ldap and oidc authentication can not be used together, "+
+			ldapPath.String()+" references an ldap authentication class"))
+	}
+
+	return allErrs, refErrs, nil
+}
+
+// validateMetadataDatabase checks exactly one database variant is set and its credentials secret has the expected keys.
+func (v *AirflowClusterCustomValidator) validateMetadataDatabase(
+	ctx context.Context,
+	namespace string,
+	metadataDatabase *airflowv1alpha1.MetadataDatabaseSpec,
+	path *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+	if metadataDatabase == nil {
+		return nil, nil, nil
+	}
+
+	var conn *airflowv1alpha1.DatabaseConnectionSpec
+	var connPath *field.Path
+	switch {
+	case metadataDatabase.Postgresql != nil && metadataDatabase.Mysql != nil:
+		return append(allErrs, field.Forbidden(path.Child("mysql"), "postgresql and mysql can not be set at the same time")), nil, nil
+	case metadataDatabase.Postgresql != nil:
+		conn, connPath = &metadataDatabase.Postgresql.DatabaseConnectionSpec, path.Child("postgresql")
+	case metadataDatabase.Mysql != nil:
+		conn, connPath = &metadataDatabase.Mysql.DatabaseConnectionSpec, path.Child("mysql")
+	default:
+		return append(allErrs, field.Required(path, "one of postgresql or mysql must be set")), nil, nil
+	}
+
+	secretPath := connPath.Child("credentialsSecret")
+	if conn.CredentialsSecret == "" {
+		return append(allErrs, field.Required(secretPath, "")), nil, nil
+	}
+	refErrs, err = v.validateSecretKeys(ctx, namespace, conn.CredentialsSecret, requiredDatabaseCredentialsKeys, secretPath)
+	return nil, refErrs, err
+}
+
+// validateCeleryBroker checks exactly one broker variant is set and the referenced credentials secrets have the expected keys.
+func (v *AirflowClusterCustomValidator) validateCeleryBroker(
+	ctx context.Context,
+	namespace string,
+	celery *airflowv1alpha1.CeleryExecutorsSpec,
+	path *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+	if celery.Broker == nil {
+		if celery.ResultBackend != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("resultBackend"), "resultBackend is only used together with broker"))
+		}
+		return allErrs, nil, nil
+	}
+
+	brokerPath := path.Child("broker")
+	variants := map[string]*airflowv1alpha1.BrokerCredentialsSpec{}
+	if broker := celery.Broker.Redis; broker != nil {
+		variants["redis"] = &broker.BrokerCredentialsSpec
+	}
+	if broker := celery.Broker.RedisSentinel; broker != nil {
+		variants["redisSentinel"] = &broker.BrokerCredentialsSpec
+	}
+	if broker := celery.Broker.Rabbitmq; broker != nil {
+		variants["rabbitmq"] = &broker.BrokerCredentialsSpec
+	}
+	if len(variants) != 1 {
+		return append(allErrs, field.Invalid(brokerPath, slices.Sorted(maps.Keys(variants)),
+			"exactly one of redis, redisSentinel or rabbitmq must be set")), nil, nil
+	}
+
+	for name, creds := range variants {
+		keys := []string{common.DatabaseCredentialsPasswordKey}
+		if name == "rabbitmq" {
+			keys = requiredDatabaseCredentialsKeys
+		}
+		refs, err := v.validateSecretKeys(ctx, namespace, creds.CredentialsSecret, keys, brokerPath.Child(name, "credentialsSecret"))
+		if err != nil {
+			return nil, nil, err
+		}
+		refErrs = append(refErrs, refs...)
+	}
+
+	if celery.ResultBackend != nil && celery.ResultBackend.Redis != nil {
+		refs, err := v.validateSecretKeys(ctx, namespace, celery.ResultBackend.Redis.CredentialsSecret,
+			[]string{common.DatabaseCredentialsPasswordKey}, path.Child("resultBackend", "redis", "credentialsSecret"))
+		if err != nil {
+			return nil, nil, err
+		}
+		refErrs = append(refErrs, refs...)
+	}
+
+	return allErrs, refErrs, nil
+}
+
+// validateSecretKeys checks the optional secret exists and has the keys, the errors are about the referenced secret
+func (v *AirflowClusterCustomValidator) validateSecretKeys(
+	ctx context.Context,
+	namespace string,
+	name string,
+	keys []string,
+	path *field.Path,
+) (field.ErrorList, error) {
+	var allErrs field.ErrorList
+	if name == "" {
+		return allErrs, nil
+	}
+	secret, err := v.getSecret(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return append(allErrs, field.NotFound(path, name)), nil
+	}
+	for _, key := range keys {
+		if _, ok := secret.Data[key]; !ok {
+			allErrs = append(allErrs, field.Invalid(path, name, "secret is missing key "+key))
+		}
+	}
+	return allErrs, nil
+}
+
+// validateCeleryAutoscaling checks the replica bounds and that the source KEDA reads the queue length from is configured
+func (v *AirflowClusterCustomValidator) validateCeleryAutoscaling(
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	celery *airflowv1alpha1.CeleryExecutorsSpec,
+	path *field.Path,
+) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, name := range slices.Sorted(maps.Keys(celery.RoleGroups)) {
+		autoscaling := celery.RoleGroups[name].Autoscaling
+		if autoscaling == nil {
+			continue
+		}
+		autoscalingPath := path.Child("roleGroups").Key(name).Child("autoscaling")
+
+		if autoscaling.MinReplicas != nil && *autoscaling.MinReplicas > autoscaling.MaxReplicas {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("minReplicas"), *autoscaling.MinReplicas,
+				"must be less than or equal to maxReplicas"))
+		}
+
+		switch autoscaling.Source {
+		case airflowv1alpha1.CeleryAutoscalingSourceBroker:
+			if celery.Broker == nil {
+				allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("source"), autoscaling.Source,
+					"requires celeryExecutors.broker"))
+			}
+		default:
+			if clusterConfig.MetadataDatabase == nil {
+				allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("source"), autoscaling.Source,
+					"requires clusterConfig.metadataDatabase"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateGracefulShutdownTimeouts checks the timeouts of the roles and role groups parse as durations,
+// they become the termination grace period of the pods.
+func (v *AirflowClusterCustomValidator) validateGracefulShutdownTimeouts(
+	spec *airflowv1alpha1.AirflowClusterSpec,
+	path *field.Path,
+) field.ErrorList {
+	var allErrs field.ErrorList
+
+	check := func(configPath *field.Path, config *airflowv1alpha1.ConfigSpec) {
+		if config == nil || config.RoleGroupConfigSpec == nil || config.GracefulShutdownTimeout == "" {
+			return
+		}
+		timeoutPath := configPath.Child("gracefulShutdownTimeout")
+		if timeout, err := time.ParseDuration(config.GracefulShutdownTimeout); err != nil {
+			allErrs = append(allErrs, field.Invalid(timeoutPath, config.GracefulShutdownTimeout, err.Error()))
+		} else if timeout < 0 {
+			allErrs = append(allErrs, field.Invalid(timeoutPath, config.GracefulShutdownTimeout, "must not be negative"))
+		}
+	}
+	checkRole := func(rolePath *field.Path, config *airflowv1alpha1.ConfigSpec, roleGroups map[string]airflowv1alpha1.RoleGroupSpec) {
+		check(rolePath.Child("config"), config)
+		for _, name := range slices.Sorted(maps.Keys(roleGroups)) {
+			check(rolePath.Child("roleGroups").Key(name).Child("config"), roleGroups[name].Config)
+		}
+	}
+
+	if spec.Webservers != nil {
+		checkRole(path.Child("webservers"), spec.Webservers.Config, spec.Webservers.RoleGroups)
+	}
+	if spec.Schedulers != nil {
+		checkRole(path.Child("schedulers"), spec.Schedulers.Config, spec.Schedulers.RoleGroups)
+	}
+	if spec.Triggerers != nil {
+		checkRole(path.Child("triggerers"), spec.Triggerers.Config, spec.Triggerers.RoleGroups)
+	}
+	if spec.DagProcessors != nil {
+		checkRole(path.Child("dagProcessors"), spec.DagProcessors.Config, spec.DagProcessors.RoleGroups)
+	}
+	if spec.Flower != nil {
+		checkRole(path.Child("flower"), spec.Flower.Config, spec.Flower.RoleGroups)
+	}
+	if celery := spec.CeleryExecutors; celery != nil {
+		celeryPath := path.Child("celeryExecutors")
+		if celery.Config != nil {
+			check(celeryPath.Child("config"), &celery.Config.ConfigSpec)
+		}
+		for _, name := range slices.Sorted(maps.Keys(celery.RoleGroups)) {
+			if config := celery.RoleGroups[name].Config; config != nil {
+				check(celeryPath.Child("roleGroups").Key(name).Child("config"), &config.ConfigSpec)
+			}
+		}
+	}
+	if spec.KubernetesExecutors != nil {
+		check(path.Child("kubernetesExecutors", "config"), spec.KubernetesExecutors.Config)
+	}
+
+	return allErrs
+}
+
+// validateVolumes checks the volumes decode, their names are free and every mount references a declared volume
+func (v *AirflowClusterCustomValidator) validateVolumes(
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	path *field.Path,
+) field.ErrorList {
+	_, _, allErrs := common.DecodeUserVolumes(clusterConfig, path)
+	return allErrs
+}
+
+// validateDagsGitSync checks the names of the repos are unique, and set without gitFolder when there are several
+func (v *AirflowClusterCustomValidator) validateDagsGitSync(
+	ctx context.Context,
+	namespace string,
+	clusterConfig *airflowv1alpha1.ClusterConfigSpec,
+	path *field.Path,
+) (allErrs field.ErrorList, refErrs field.ErrorList, err error) {
+	gitSyncs := common.GetGitSyncs(clusterConfig)
+	names := make(map[string]bool)
+	for i, gitSync := range gitSyncs {
+		repoPath := path.Index(i)
+
+		name := gitSync.GetName()
+		if names[name] {
+			allErrs = append(allErrs, field.Duplicate(repoPath.Child("name"), name))
+		}
+		names[name] = true
+
+		// several repos share the common root of their checkouts as DAGs folder
+		if len(gitSyncs) > 1 {
+			if gitSync.Spec.Name == "" {
+				allErrs = append(allErrs, field.Required(repoPath.Child("name"),
+					"a name is required with several repos, the default name moves with the position of the repo"))
+			}
+			if gitSync.Spec.GitFolder != "" {
+				allErrs = append(allErrs, field.Forbidden(repoPath.Child("gitFolder"),
+					"gitFolder is only supported with a single repo, several repos are loaded from the root of their checkouts"))
+			}
+		}
+
+		if secretName := gitSync.Spec.CrdentialsSecret; secretName != "" {
+			secret, err := v.getSecret(ctx, namespace, secretName)
+			if err != nil {
+				return nil, nil, err
+			}
+			if secret == nil {
+				refErrs = append(refErrs, field.NotFound(repoPath.Child("crdentialsSecretName"), secretName))
+			}
+		}
+	}
+
+	return allErrs, refErrs, nil
+}
+
+// getSecret returns nil if the secret does not exist
+func (v *AirflowClusterCustomValidator) getSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := v.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2024 ZNCDataDev.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airflowv1alpha1 "github.com/zncdatadev/airflow-operator/api/v1alpha1"
+)
+
+var _ = Describe("AirflowCluster Webhook", func() {
+	var (
+		ctx       = context.Background()
+		obj       *airflowv1alpha1.AirflowCluster
+		objects   []client.Object
+		validator *AirflowClusterCustomValidator
+	)
+
+	credentials := func(keys ...string) *corev1.Secret {
+		data := map[string][]byte{}
+		for _, key := range slices.Concat(requiredCredentialsKeys, keys) {
+			data[key] = []byte("value")
+		}
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow-credentials", Namespace: "default"},
+			Data:       data,
+		}
+	}
+
+	BeforeEach(func() {
+		obj = &airflowv1alpha1.AirflowCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "airflow", Namespace: "default"},
+			Spec: airflowv1alpha1.AirflowClusterSpec{
+				ClusterConfig: &airflowv1alpha1.ClusterConfigSpec{
+					Credentials: "airflow-credentials",
+				},
+			},
+		}
+		objects = []client.Object{credentials(metadataDatabaseCredentialsKey)}
+	})
+
+	JustBeforeEach(func() {
+		validator = &AirflowClusterCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		}
+	})
+
+	expectFieldError := func(err error, fieldPath string) {
+		Expect(apierrors.IsInvalid(err)).To(BeTrue(), "expected an invalid error, got %v", err)
+		statusErr := err.(*apierrors.StatusError)
+		fields := []string{}
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		Expect(fields).To(ContainElement(fieldPath))
+	}
+
+	It("admits a valid cluster", func() {
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects a missing credentials secret", func() {
+		obj.Spec.ClusterConfig.Credentials = "missing"
+		_, err := validator.ValidateCreate(ctx, obj)
+		expectFieldError(err, "spec.clusterConfig.credentialsSecret")
+	})
+
+	It("warns about a missing credentials secret on update", func() {
+		obj.Spec.ClusterConfig.Credentials = "missing"
+		warnings, err := validator.ValidateUpdate(ctx, obj, obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(ContainElement(ContainSubstring("spec.clusterConfig.credentialsSecret")))
+	})
+
+	It("rejects an unsupported product version", func() {
+		obj.Spec.Image = &airflowv1alpha1.ImageSpec{ProductVersion: "1.10.15"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		expectFieldError(err, "spec.image.productVersion")
+	})
+
+	It("rejects a custom image without product version", func() {
+		obj.Spec.Image = &airflowv1alpha1.ImageSpec{Custom: "registry.example.com/airflow:3.0.6"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		expectFieldError(err, "spec.image.productVersion")
+	})
+
+	It("rejects a graceful shutdown timeout that is not a duration", func() {
+		obj.Spec.Webservers = &airflowv1alpha1.WebserversSpec{
+			RoleGroups: map[string]airflowv1alpha1.RoleGroupSpec{
+				"default": {Config: &airflowv1alpha1.ConfigSpec{
+					RoleGroupConfigSpec: &commonsv1alpha1.RoleGroupConfigSpec{GracefulShutdownTimeout: "2 minutes"},
+				}},
+			},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		expectFieldError(err, "spec.webservers.roleGroups[default].config.gracefulShutdownTimeout")
+	})
+
+	Context("with flower", func() {
+		BeforeEach(func() {
+			obj.Spec.Flower = &airflowv1alpha1.FlowerSpec{CredentialsSecret: "flower"}
+			objects = append(objects, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "flower", Namespace: "default"},
+				Data:       map[string][]byte{"username": []byte("admin")},
+			})
+		})
+
+		It("rejects flower without celery executors", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.flower")
+		})
+
+		It("rejects credentials without password", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.flower.credentialsSecret")
+		})
+	})
+
+	Context("with volumes", func() {
+		raw := func(obj string) k8sruntime.RawExtension {
+			return k8sruntime.RawExtension{Raw: []byte(obj)}
+		}
+
+		BeforeEach(func() {
+			obj.Spec.ClusterConfig.Volumes = []k8sruntime.RawExtension{raw(`{"name": "plugins", "emptyDir": {}}`)}
+		})
+
+		It("admits mounts of declared volumes", func() {
+			obj.Spec.ClusterConfig.VolumeMounts = []k8sruntime.RawExtension{raw(`{"name": "plugins", "mountPath": "/plugins"}`)}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects a mount of an undeclared volume", func() {
+			obj.Spec.ClusterConfig.VolumeMounts = []k8sruntime.RawExtension{raw(`{"name": "certs", "mountPath": "/certs"}`)}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.volumeMounts[0].name")
+		})
+
+		It("rejects a volume with unknown fields", func() {
+			obj.Spec.ClusterConfig.Volumes = append(obj.Spec.ClusterConfig.Volumes, raw(`{"name": "data", "emptyDirectory": {}}`))
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.volumes[1]")
+		})
+
+		It("rejects the names of the volumes of the operator", func() {
+			obj.Spec.ClusterConfig.Volumes = append(obj.Spec.ClusterConfig.Volumes,
+				raw(`{"name": "config", "emptyDir": {}}`),
+				raw(`{"name": "ldap-bind", "emptyDir": {}}`),
+			)
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.volumes[1].name")
+			expectFieldError(err, "spec.clusterConfig.volumes[2].name")
+		})
+	})
+
+	Context("with several dag repos", func() {
+		BeforeEach(func() {
+			obj.Spec.ClusterConfig.DagsGitSync = []airflowv1alpha1.DagsGitSyncSpec{
+				{Name: "etl", Repo: "https://git.example.com/etl.git"},
+				{Name: "ml", Repo: "https://git.example.com/ml.git"},
+			}
+		})
+
+		It("admits named repos", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects a repo without name", func() {
+			obj.Spec.ClusterConfig.DagsGitSync[1].Name = ""
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.dagsGitSync[1].name")
+		})
+
+		It("rejects a git folder", func() {
+			obj.Spec.ClusterConfig.DagsGitSync[0].GitFolder = "dags"
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.dagsGitSync[0].gitFolder")
+		})
+	})
+
+	Context("with celery executors", func() {
+		BeforeEach(func() {
+			obj.Spec.CeleryExecutors = &airflowv1alpha1.CeleryExecutorsSpec{}
+		})
+
+		It("rejects a cluster without broker", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.celeryExecutors")
+		})
+
+		Context("and a typed broker", func() {
+			BeforeEach(func() {
+				obj.Spec.CeleryExecutors.Broker = &airflowv1alpha1.CeleryBrokerSpec{
+					Redis: &airflowv1alpha1.RedisSpec{
+						Host: "redis",
+						BrokerCredentialsSpec: airflowv1alpha1.BrokerCredentialsSpec{
+							CredentialsSecret: "redis",
+						},
+					},
+				}
+				objects = append(objects, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: "default"},
+					Data:       map[string][]byte{"password": []byte("redis")},
+				})
+			})
+
+			It("does not require broker keys in the credentials secret", func() {
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects several broker variants", func() {
+				obj.Spec.CeleryExecutors.Broker.Rabbitmq = &airflowv1alpha1.RabbitmqSpec{Host: "rabbitmq"}
+				_, err := validator.ValidateCreate(ctx, obj)
+				expectFieldError(err, "spec.celeryExecutors.broker")
+			})
+
+			It("rejects a missing broker credentials secret", func() {
+				obj.Spec.CeleryExecutors.Broker.Redis.CredentialsSecret = "missing"
+				_, err := validator.ValidateCreate(ctx, obj)
+				expectFieldError(err, "spec.celeryExecutors.broker.redis.credentialsSecret")
+			})
+
+			It("admits autoscaling on the broker", func() {
+				obj.Spec.CeleryExecutors.RoleGroups = map[string]airflowv1alpha1.CeleryRoleGroupSpec{
+					"default": {Autoscaling: &airflowv1alpha1.CeleryAutoscalingSpec{
+						MaxReplicas: 5,
+						Source:      airflowv1alpha1.CeleryAutoscalingSourceBroker,
+					}},
+				}
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects autoscaling on the database without metadata database", func() {
+				obj.Spec.CeleryExecutors.RoleGroups = map[string]airflowv1alpha1.CeleryRoleGroupSpec{
+					"default": {Autoscaling: &airflowv1alpha1.CeleryAutoscalingSpec{
+						MaxReplicas: 5,
+						Source:      airflowv1alpha1.CeleryAutoscalingSourceDatabase,
+					}},
+				}
+				_, err := validator.ValidateCreate(ctx, obj)
+				expectFieldError(err, "spec.celeryExecutors.roleGroups[default].autoscaling.source")
+			})
+
+			It("rejects more min than max replicas", func() {
+				obj.Spec.CeleryExecutors.RoleGroups = map[string]airflowv1alpha1.CeleryRoleGroupSpec{
+					"default": {Autoscaling: &airflowv1alpha1.CeleryAutoscalingSpec{
+						MinReplicas: ptr.To[int32](3),
+						MaxReplicas: 2,
+						Source:      airflowv1alpha1.CeleryAutoscalingSourceBroker,
+					}},
+				}
+				_, err := validator.ValidateCreate(ctx, obj)
+				expectFieldError(err, "spec.celeryExecutors.roleGroups[default].autoscaling.minReplicas")
+			})
+		})
+
+		Context("and a broker", func() {
+			BeforeEach(func() {
+				objects = []client.Object{credentials(append([]string{metadataDatabaseCredentialsKey}, requiredCeleryCredentialsKeys...)...)}
+			})
+
+			It("admits the cluster", func() {
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Context("with a metadata database", func() {
+		BeforeEach(func() {
+			obj.Spec.ClusterConfig.MetadataDatabase = &airflowv1alpha1.MetadataDatabaseSpec{
+				Postgresql: &airflowv1alpha1.PostgresqlSpec{
+					DatabaseConnectionSpec: airflowv1alpha1.DatabaseConnectionSpec{
+						Host:              "postgresql",
+						Database:          "airflow",
+						CredentialsSecret: "airflow-db",
+					},
+				},
+			}
+			objects = []client.Object{
+				credentials(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "airflow-db", Namespace: "default"},
+					Data: map[string][]byte{
+						"username": []byte("airflow"),
+						"password": []byte("airflow"),
+					},
+				},
+			}
+		})
+
+		It("does not require a database uri in the credentials secret", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects a missing database credentials secret", func() {
+			obj.Spec.ClusterConfig.MetadataDatabase.Postgresql.CredentialsSecret = "missing"
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.metadataDatabase.postgresql.credentialsSecret")
+		})
+
+		It("rejects both database variants", func() {
+			obj.Spec.ClusterConfig.MetadataDatabase.Mysql = &airflowv1alpha1.MysqlSpec{}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.metadataDatabase.mysql")
+		})
+	})
+
+	Context("with authentication", func() {
+		BeforeEach(func() {
+			for _, name := range []string{"ldap", "ldap-backup"} {
+				objects = append(objects, &authv1alpha1.AuthenticationClass{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Spec: authv1alpha1.AuthenticationClassSpec{
+						AuthenticationProvider: &authv1alpha1.AuthenticationProvider{
+							LDAP: &authv1alpha1.LDAPProvider{Hostname: name + ".example.com"},
+						},
+					},
+				})
+			}
+			objects = append(objects, &authv1alpha1.AuthenticationClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "oidc"},
+				Spec: authv1alpha1.AuthenticationClassSpec{
+					AuthenticationProvider: &authv1alpha1.AuthenticationProvider{
+						OIDC: &authv1alpha1.OIDCProvider{Hostname: "keycloak.example.com", ProviderHint: "keycloak"},
+					},
+				},
+			})
+		})
+
+		It("rejects an authentication class that does not exist", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "missing"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[0].authenticationClass")
+		})
+
+		It("rejects mismatched syncRolesAt", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "ldap", SyncRolesAt: "Registration"},
+				{AuthenticationClass: "ldap-backup", SyncRolesAt: "Login"},
+			}
+			_, err := validator.ValidateUpdate(ctx, obj, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[1].syncRolesAt")
+		})
+
+		It("rejects an authentication class referenced twice", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "ldap"},
+				{AuthenticationClass: "ldap"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[1].authenticationClass")
+		})
+
+		It("rejects ldap together with oidc", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "ldap"},
+				{AuthenticationClass: "oidc", Oidc: &authv1alpha1.OidcSpec{ClientCredentialsSecret: "oidc-client"}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[1].authenticationClass")
+		})
+
+		It("rejects a roles claim on an ldap authentication class", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "ldap", RolesClaim: "groups"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[0].rolesClaim")
+		})
+
+		It("rejects a role mapping without roles", func() {
+			obj.Spec.ClusterConfig.Authentication = []airflowv1alpha1.AuthenticationSpec{
+				{AuthenticationClass: "ldap", RoleMapping: map[string][]string{
+					"cn=admins,ou=groups,dc=example,dc=org": {"Admin"},
+					"cn=users,ou=groups,dc=example,dc=org":  {},
+				}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			expectFieldError(err, "spec.clusterConfig.authentication[0].roleMapping[cn=users,ou=groups,dc=example,dc=org]")
+		})
+	})
+})